@@ -18,13 +18,28 @@ type Variable struct {
 	declared bool
 	used     bool
 	changed  bool
+	local    bool
+	field    bool
+	enum     *Enum
+	source   parser.Token
 }
 
 type List struct {
 	ID       string
 	Name     parser.Token
 	DataType parser.DataType
+	// Rows and Columns are the dimensions of 2D lists, which are stored row by row. They are 0 for all other lists.
+	Rows     int
+	Columns  int
+	Constant bool
 	used     bool
+	local    bool
+	field    bool
+	// placeholder is true for the lists which are passed to templates when they are checked.
+	placeholder bool
+	source      parser.Token
+	// values contains the values of constant lists after they have been calculated.
+	values []any
 }
 
 type Constant struct {
@@ -33,21 +48,31 @@ type Constant struct {
 	Value     any
 	Type      parser.DataType
 	used      bool
+	enum      *Enum
 }
 
 type Function struct {
-	Name        parser.Token
-	Params      []parser.FuncParam
-	ProcCode    string
-	ArgumentIDs []string
-	StartLine   int
-	EndLine     int
-	used        bool
+	Name           parser.Token
+	Params         []parser.FuncParam
+	ReturnType     parser.DataType
+	ReturnVariable *Variable
+	Warp           bool
+	ProcCode       string
+	ArgumentIDs    []string
+	StartLine      int
+	EndLine        int
+	used           bool
+
+	template        *parser.StmtFuncDecl
+	specializations map[string]*Function
 }
 
 type CustomEvent struct {
-	ID        string
-	Name      parser.Token
+	ID     string
+	Name   parser.Token
+	Params []parser.FuncParam
+	// Payload contains the hidden variables which pass the parameters to the handlers.
+	Payload   []*Variable
 	triggered bool
 	consumed  bool
 }
@@ -59,17 +84,37 @@ type analyzer struct {
 	functions map[string]*Function
 	events    map[string]*CustomEvent
 
+	records         map[string]*Record
+	recordVariables map[string]*recordVariable
+	enums           map[string]*Enum
+
 	variableIsList bool
 
 	currentFunction *Function
+	scopeName       string
+	scopes          []map[string]string
+	loops           []*loop
 
 	errors   []error
 	warnings []error
 
 	unreachable bool
 
+	hoisted      []parser.Stmt
+	pendingReads []*pendingRead
+	tempCounts   map[string]int
+	// replaceStatement is true if the current statement is replaced by the hoisted statements.
+	replaceStatement bool
+
+	specializations []*specialization
+	// helpers contains the parsed helper functions by the name of the builtin function they implement.
+	helpers map[string][]*parser.StmtFuncDecl
+
 	launchEventCount     int
 	variableInitializers []parser.Stmt
+
+	// exported contains the names of the exported declarations of imported modules. They are not reported as unused.
+	exported map[string]bool
 }
 
 type Definitions struct {
@@ -93,20 +138,33 @@ func Analyze(statements []parser.Stmt) ([]parser.Stmt, AnalyzerResult) {
 		constants:            make(map[string]*Constant),
 		functions:            make(map[string]*Function),
 		events:               make(map[string]*CustomEvent),
+		records:              make(map[string]*Record),
+		recordVariables:      make(map[string]*recordVariable),
+		enums:                make(map[string]*Enum),
 		errors:               make([]error, 0),
 		warnings:             make([]error, 0),
 		variableInitializers: make([]parser.Stmt, 0),
+		exported:             make(map[string]bool),
 	}
+	statements = a.resolveModules(statements)
 	for _, stmt := range statements {
 		err := stmt.Accept(a)
 		if err != nil {
 			a.errors = append(a.errors, err)
 		}
 	}
+	statements = a.analyzeSpecializations(statements)
 
 	if len(a.errors) == 0 {
 		for _, v := range a.variables {
-			if !v.used {
+			if v.field || a.exported[v.Name.Lexeme] {
+				continue
+			}
+			if v.local {
+				if !v.used {
+					a.newWarningTk("This variable is never used.", v.source)
+				}
+			} else if !v.used {
 				a.newWarningTk("This variable is never used.", v.Name)
 			} else if !v.changed {
 				a.newWarningTk("The value of this variable is never changed. Consider using 'const' instead.", v.Name)
@@ -114,24 +172,39 @@ func Analyze(statements []parser.Stmt) ([]parser.Stmt, AnalyzerResult) {
 		}
 
 		for _, l := range a.lists {
-			if !l.used {
-				a.newWarningTk("This variable is never used.", l.Name)
+			if !l.used && !l.field && !a.exported[l.Name.Lexeme] {
+				if l.Constant {
+					a.newWarningTk("This constant is never used.", l.Name)
+				} else if l.local {
+					a.newWarningTk("This variable is never used.", l.source)
+				} else {
+					a.newWarningTk("This variable is never used.", l.Name)
+				}
+			}
+		}
+
+		for _, r := range a.recordVariables {
+			if !a.isRecordUsed(r) && !a.exported[r.Name.Lexeme] {
+				a.newWarningTk("This variable is never used.", r.source)
 			}
 		}
 
 		for _, c := range a.constants {
-			if !c.used {
+			if !c.used && c.enum == nil && !a.exported[c.Name.Lexeme] {
 				a.newWarningTk("This constant is never used.", c.Name)
 			}
 		}
 
 		for _, f := range a.functions {
-			if !f.used {
+			if !f.used && !a.exported[f.Name.Lexeme] {
 				a.newWarningTk("This function is never called.", f.Name)
 			}
 		}
 
 		for _, e := range a.events {
+			if a.exported[e.Name.Lexeme] {
+				continue
+			}
 			if !e.triggered {
 				a.newWarningTk("This event is never triggered.", e.Name)
 			} else if !e.consumed {
@@ -198,23 +271,53 @@ func Analyze(statements []parser.Stmt) ([]parser.Stmt, AnalyzerResult) {
 		a.warnings = append(a.warnings, cWarns...)
 	}
 
+	// the user cannot fix problems in the bundled library
+	warnings := make([]error, 0, len(a.warnings))
+	for _, w := range a.warnings {
+		if e, ok := w.(AnalyzerError); !ok || !parser.IsLibraryPath(e.Start.Path) {
+			warnings = append(warnings, w)
+		}
+	}
+
 	return statements, AnalyzerResult{
 		Definitions: definitions,
-		Warnings:    a.warnings,
-		Errors:      a.errors,
+		Warnings:    removeDuplicates(warnings, 0),
+		Errors:      removeDuplicates(a.errors, 0),
 	}
 }
 
 func (a *analyzer) VisitVarDecl(stmt *parser.StmtVarDecl) error {
-	if err := a.assertNotDeclared(stmt.Name); err != nil {
+	enum := a.enumType(stmt)
+	if record, list, err := a.recordType(stmt); err != nil {
 		return err
+	} else if record != nil {
+		return a.declareRecordVariable(stmt, record, list)
+	}
+
+	local := len(a.scopes) > 0
+	name := stmt.Name
+	if local {
+		if a.unreachable {
+			a.newWarningStmt("Unreachable code.", stmt)
+		}
+		if err := a.assertNotDeclaredLocal(stmt.Name); err != nil {
+			return err
+		}
+		name = a.declareLocal(stmt.Name)
+	} else {
+		if err := a.assertNotDeclared(stmt.Name); err != nil {
+			return err
+		}
+		a.scopeName = stmt.Name.Lexeme
 	}
 
 	if _, ok := stmt.Value.(*parser.ExprListInitializer); ok || strings.HasSuffix(string(stmt.DataType), "[]") {
 		list := &List{
 			ID:       uuid.NewString(),
-			Name:     stmt.Name,
+			Name:     name,
 			DataType: stmt.DataType,
+			local:    local,
+			source:   stmt.Name,
 		}
 
 		if stmt.Value == nil {
@@ -232,8 +335,18 @@ func (a *analyzer) VisitVarDecl(stmt *parser.StmtVarDecl) error {
 			return a.newErrorExpr("Expected a list initializer.", stmt.Value)
 		}
 
+		if len(stmt.Dimensions) > 0 || isNestedList(init) {
+			var err error
+			list.Rows, list.Columns, err = a.listDimensions(stmt, init)
+			if err != nil {
+				a.undeclareLocal(stmt.Name)
+				return err
+			}
+		}
+
+		a.beginStatement()
 		valueType := parser.DataType(strings.TrimSuffix(string(stmt.DataType), "[]"))
-		for _, v := range init.Values {
+		for i, v := range init.Values {
 			err := v.Accept(a)
 			if err != nil {
 				a.errors = append(a.errors, err)
@@ -246,23 +359,19 @@ func (a *analyzer) VisitVarDecl(stmt *parser.StmtVarDecl) error {
 				a.errors = append(a.errors, a.newErrorExpr(fmt.Sprintf("Wrong data type. Expected %s.", valueType), v))
 				continue
 			}
+			init.Values[i] = a.hoistCalls(v)
 		}
 		if valueType != "" {
 			list.DataType = valueType + "[]"
 		}
 
 		if list.DataType == "" {
+			a.undeclareLocal(stmt.Name)
 			return a.newErrorTk("Cannot infer the data type of the variable. Please explicitly provide type information.", stmt.Name)
 		}
 
-		if list.DataType == "boolean[]" {
-			if start, _ := stmt.Value.Position(); start == stmt.Name.Pos {
-				stmt.Value = nil
-				return a.newErrorStmt("Boolean lists are not supported", stmt)
-			}
-			return a.newErrorExpr("Boolean lists are not supported.", stmt.Value)
-		}
 		if list.DataType == "image[]" {
+			a.undeclareLocal(stmt.Name)
 			if start, _ := stmt.Value.Position(); start == stmt.Name.Pos {
 				stmt.Value = nil
 				return a.newErrorStmt("Image lists are not supported", stmt)
@@ -272,6 +381,21 @@ func (a *analyzer) VisitVarDecl(stmt *parser.StmtVarDecl) error {
 
 		a.lists[list.Name.Lexeme] = list
 
+		initializers := a.hoisted
+		if local {
+			initializers = append(initializers, &parser.StmtCall{
+				Name: parser.Token{
+					Type:   parser.TkIdentifier,
+					Lexeme: "lists.clear",
+				},
+				Parameters: []parser.Expr{
+					&parser.ExprIdentifier{
+						Name:       list.Name,
+						ReturnType: list.DataType,
+					},
+				},
+			})
+		}
 		for _, v := range init.Values {
 			assign := &parser.StmtCall{
 				Name: parser.Token{
@@ -286,13 +410,19 @@ func (a *analyzer) VisitVarDecl(stmt *parser.StmtVarDecl) error {
 					v,
 				},
 			}
-			a.variableInitializers = append(a.variableInitializers, assign)
+			initializers = append(initializers, assign)
 		}
+		if list.Rows > 0 && len(init.Values) == 0 {
+			initializers = append(initializers, fillTable(list))
+		}
+		a.addInitializers(local, initializers)
 	} else {
 		variable := &Variable{
 			ID:       uuid.NewString(),
-			Name:     stmt.Name,
+			Name:     name,
 			DataType: stmt.DataType,
+			local:    local,
+			source:   stmt.Name,
 		}
 
 		if variable.DataType != "" && stmt.Value == nil {
@@ -320,6 +450,16 @@ func (a *analyzer) VisitVarDecl(stmt *parser.StmtVarDecl) error {
 					},
 					ReturnType: parser.DTString,
 				}
+			case parser.DTBool:
+				stmt.Value = &parser.ExprLiteral{
+					Token: parser.Token{
+						Type:     parser.TkLiteral,
+						Lexeme:   "false",
+						Literal:  false,
+						DataType: parser.DTBool,
+					},
+					ReturnType: parser.DTBool,
+				}
 			case parser.DTImage:
 				stmt.Value = &parser.ExprTypeCast{
 					Target: parser.Token{
@@ -339,41 +479,52 @@ func (a *analyzer) VisitVarDecl(stmt *parser.StmtVarDecl) error {
 					ReturnType: parser.DTImage,
 				}
 			default:
+				a.undeclareLocal(stmt.Name)
 				return a.newErrorStmt(fmt.Sprintf("%s variables are not supported.", strings.ToTitle(string(variable.DataType))), stmt)
 			}
 		}
 
-		a.variables[stmt.Name.Lexeme] = variable
+		a.variables[name.Lexeme] = variable
 		if stmt.Value != nil {
 			assign := &parser.StmtAssignment{
 				Variable: stmt.Name,
 				Operator: stmt.AssignToken,
 				Value:    stmt.Value,
 			}
+			a.beginStatement()
 			err := assign.Accept(a)
 			if err != nil {
-				delete(a.variables, stmt.Name.Lexeme)
+				delete(a.variables, name.Lexeme)
+				a.undeclareLocal(stmt.Name)
 				return err
 			}
 			variable.DataType = assign.Value.Type()
-			a.variableInitializers = append(a.variableInitializers, assign)
+			a.addInitializers(local, append(a.hoisted, assign))
 		}
 
 		if variable.DataType == "" {
-			delete(a.variables, stmt.Name.Lexeme)
+			delete(a.variables, name.Lexeme)
+			a.undeclareLocal(stmt.Name)
 			return a.newErrorTk("Cannot infer the data type of the variable. Please explicitly provide type information.", stmt.Name)
 		}
 
-		if variable.DataType == parser.DTBool {
-			return a.newErrorStmt("Boolean variables are not supported.", stmt)
-		}
-
 		variable.declared = true
+
+		if enum == nil && stmt.DataType == "" {
+			enum = a.enumOf(stmt.Value)
+		}
+		variable.enum = enum
+		if enum != nil && a.enumOf(stmt.Value) != enum {
+			return a.newErrorExpr(fmt.Sprintf("Expected a %s value.", enum.Name.Lexeme), stmt.Value)
+		}
 	}
 	return nil
 }
 
 func (a *analyzer) VisitConstDecl(stmt *parser.StmtConstDecl) error {
+	if init, ok := stmt.Value.(*parser.ExprListInitializer); ok {
+		return a.declareConstList(stmt, init)
+	}
 	if err := a.assertNotDeclared(stmt.Name); err != nil {
 		return err
 	}
@@ -399,6 +550,16 @@ func (a *analyzer) VisitFuncDecl(stmt *parser.StmtFuncDecl) error {
 	if err := a.assertNotDeclared(stmt.Name); err != nil {
 		return err
 	}
+	if hasReferenceParams(stmt.Params) {
+		a.declareTemplate(stmt)
+		a.checkTemplate(a.functions[stmt.Name.Lexeme])
+		return nil
+	}
+	return a.analyzeFunction(stmt, a.declareFunction(stmt))
+}
+
+// declareFunction registers the signature of stmt.
+func (a *analyzer) declareFunction(stmt *parser.StmtFuncDecl) *Function {
 	procCode := stmt.Name.Lexeme
 	argumentIDs := make([]string, 0, len(stmt.Params))
 	argumentNames := make([]string, 0, len(stmt.Params))
@@ -423,18 +584,67 @@ func (a *analyzer) VisitFuncDecl(stmt *parser.StmtFuncDecl) error {
 		}
 	}
 
-	a.functions[stmt.Name.Lexeme] = &Function{
+	fn := &Function{
 		Name:        stmt.Name,
 		Params:      stmt.Params,
+		Warp:        stmt.Warp,
 		ProcCode:    procCode,
 		ArgumentIDs: argumentIDs,
 		StartLine:   stmt.StartLine,
 		EndLine:     stmt.EndLine,
 	}
+	a.functions[stmt.Name.Lexeme] = fn
+
+	if stmt.ReturnType.Type == parser.TkType {
+		switch stmt.ReturnType.DataType {
+		case parser.DTNumber, parser.DTString, parser.DTBool:
+			fn.ReturnType = stmt.ReturnType.DataType
+			fn.ReturnVariable = a.hiddenVariable("$"+stmt.Name.Lexeme+".return", fn.ReturnType)
+		case parser.DTImage:
+			a.errors = append(a.errors, a.newErrorTk("Image return values are not supported.", stmt.ReturnType))
+		default:
+			a.errors = append(a.errors, a.newErrorTk("List return values are not supported.", stmt.ReturnType))
+		}
+	}
+	return fn
+}
+
+// analyzeFunction analyzes the body of a function declared with declareFunction.
+func (a *analyzer) analyzeFunction(stmt *parser.StmtFuncDecl, fn *Function) error {
+	a.currentFunction = fn
+	a.scopeName = stmt.Name.Lexeme
+
+	// Parameters are argument reporters which cannot be assigned to.
+	// Reassigned parameters are therefore copied into local variables at the start of the function.
+	a.scopes = append(a.scopes, make(map[string]string))
+	paramCopies := make([]parser.Stmt, 0)
+	assigned := assignedVariables(stmt.Body)
+	for _, p := range stmt.Params {
+		if !assigned[p.Name.Lexeme] {
+			continue
+		}
+		name := a.declareLocal(p.Name)
+		variable := a.hiddenVariable(name.Lexeme, p.Type.DataType)
+		variable.Name = name
+		paramCopies = append(paramCopies, &parser.StmtAssignment{
+			Variable: name,
+			Operator: parser.Token{
+				Type: parser.TkAssign,
+			},
+			Value: &parser.ExprIdentifier{
+				Name:       p.Name,
+				ReturnType: p.Type.DataType,
+			},
+		})
+	}
+	stmt.Body = append(paramCopies, a.visitBody(stmt.Body)...)
+	a.scopes = a.scopes[:len(a.scopes)-1]
 
-	a.currentFunction = a.functions[stmt.Name.Lexeme]
-	a.visitBody(stmt.Body)
 	a.currentFunction = nil
+
+	if fn.ReturnVariable != nil && !alwaysReturns(stmt.Body) {
+		return a.newErrorTk("Missing return statement.", stmt.Name)
+	}
 	return nil
 }
 
@@ -443,8 +653,22 @@ func (a *analyzer) VisitEvent(stmt *parser.StmtEvent) error {
 		if stmt.Parameter != nil {
 			a.errors = append(a.errors, a.newErrorExpr("This event does not take a parameter.", stmt.Parameter))
 		}
+		if stmt.Params != nil && len(stmt.Params) != len(e.Params) {
+			a.errors = append(a.errors, a.newErrorStmt(fmt.Sprintf("Wrong parameter count. Expected %d.", len(e.Params)), stmt))
+			stmt.Params = nil
+		}
 		e.consumed = true
 	} else if ev, ok := Events[stmt.Name.Lexeme]; ok {
+		// '@event (NAME):' is parsed as a list of parameter names.
+		if len(stmt.Params) == 1 {
+			stmt.Parameter = &parser.ExprIdentifier{
+				Name: stmt.Params[0],
+			}
+		} else if len(stmt.Params) > 1 {
+			a.errors = append(a.errors, a.newErrorStmt("This event takes at most one parameter.", stmt))
+		}
+		stmt.Params = nil
+
 		if ev.Param == nil && stmt.Parameter != nil {
 			a.errors = append(a.errors, a.newErrorExpr("This event does not take a parameter.", stmt.Parameter))
 		} else if ev.Param != nil {
@@ -461,9 +685,48 @@ func (a *analyzer) VisitEvent(stmt *parser.StmtEvent) error {
 		}
 	} else {
 		a.errors = append(a.errors, a.newErrorStmt("Unknown event.", stmt))
+		stmt.Params = nil
 	}
 
-	a.visitBody(stmt.Body)
+	a.scopeName = stmt.Name.Lexeme
+
+	// The payload variables are overwritten by the next broadcast of the event.
+	// They are therefore copied into local variables at the start of the handler.
+	a.scopes = append(a.scopes, make(map[string]string))
+	payloadCopies := make([]parser.Stmt, 0, len(stmt.Params))
+	for i, p := range stmt.Params {
+		if strings.Contains(p.Lexeme, ".") {
+			a.errors = append(a.errors, a.newErrorTk("Parameter names cannot contain a dot.", p))
+			continue
+		}
+		if a.assertNotDeclaredLocal(p) != nil {
+			a.errors = append(a.errors, a.newErrorTk("Duplicate parameter name.", p))
+			continue
+		}
+		payload := a.events[stmt.Name.Lexeme].Payload[i]
+		name := a.declareLocal(p)
+		variable := a.hiddenVariable(name.Lexeme, payload.DataType)
+		variable.Name = name
+		variable.local = true
+		variable.source = p
+		variable.used = false
+		payloadCopies = append(payloadCopies, newAssignStmt(name, &parser.ExprIdentifier{
+			Name:       a.hiddenToken(payload.Name.Lexeme, p),
+			ReturnType: payload.DataType,
+		}))
+	}
+	stmt.Body = append(payloadCopies, a.visitBody(stmt.Body)...)
+	a.scopes = a.scopes[:len(a.scopes)-1]
+
+	if (stmt.Name.Lexeme == "when" || stmt.Name.Lexeme == "every") && stmt.Parameter != nil {
+		if a.needsHoisting(stmt.Parameter) {
+			a.errors = append(a.errors, a.newErrorExpr("Custom functions, conditional expressions, '**' and math functions like math.min are not allowed in this context.", stmt.Parameter))
+		} else if stmt.Name.Lexeme == "when" {
+			a.lowerWhen(stmt)
+		} else {
+			a.lowerEvery(stmt)
+		}
+	}
 
 	if stmt.Name.Lexeme == "launch" {
 		a.launchEventCount++
@@ -479,9 +742,29 @@ func (a *analyzer) VisitEventDecl(stmt *parser.StmtEventDecl) error {
 		return a.newErrorTk("An event with this name already exists.", stmt.Name)
 	}
 
-	a.events[stmt.Name.Lexeme] = &CustomEvent{
-		ID:   uuid.NewString(),
-		Name: stmt.Name,
+	event := &CustomEvent{
+		ID:      uuid.NewString(),
+		Name:    stmt.Name,
+		Params:  stmt.Params,
+		Payload: make([]*Variable, 0, len(stmt.Params)),
+	}
+	a.events[stmt.Name.Lexeme] = event
+
+	names := make([]string, 0, len(stmt.Params))
+	for _, p := range stmt.Params {
+		if slices.Contains(names, p.Name.Lexeme) {
+			a.errors = append(a.errors, a.newErrorTk("Duplicate parameter name.", p.Name))
+		}
+		names = append(names, p.Name.Lexeme)
+
+		switch p.Type.DataType {
+		case parser.DTNumber, parser.DTString, parser.DTBool:
+		case parser.DTImage:
+			a.errors = append(a.errors, a.newErrorTk("Image parameters are not supported.", p.Type))
+		default:
+			a.errors = append(a.errors, a.newErrorTk("List parameters are not supported.", p.Type))
+		}
+		event.Payload = append(event.Payload, a.hiddenVariable(fmt.Sprintf("$%s.%s", stmt.Name.Lexeme, p.Name.Lexeme), p.Type.DataType))
 	}
 	return nil
 }
@@ -502,14 +785,95 @@ func (a *analyzer) assertNotDeclared(name parser.Token) error {
 	if e, ok := a.events[name.Lexeme]; ok {
 		return a.newErrorTk(fmt.Sprintf("'%s' is already declared in `%s` line %d.", name.Lexeme, filepath.Base(e.Name.Pos.Path), e.Name.Pos.Line+1), name)
 	}
+	if r, ok := a.records[name.Lexeme]; ok {
+		return a.newErrorTk(fmt.Sprintf("'%s' is already declared in `%s` line %d.", name.Lexeme, filepath.Base(r.Name.Pos.Path), r.Name.Pos.Line+1), name)
+	}
+	if r, ok := a.recordVariables[name.Lexeme]; ok {
+		return a.newErrorTk(fmt.Sprintf("'%s' is already declared in `%s` line %d.", name.Lexeme, filepath.Base(r.Name.Pos.Path), r.Name.Pos.Line+1), name)
+	}
+	if e, ok := a.enums[name.Lexeme]; ok {
+		return a.newErrorTk(fmt.Sprintf("'%s' is already declared in `%s` line %d.", name.Lexeme, filepath.Base(e.Name.Pos.Path), e.Name.Pos.Line+1), name)
+	}
 	return nil
 }
 
+func (a *analyzer) assertNotDeclaredLocal(name parser.Token) error {
+	for _, scope := range a.scopes {
+		if _, ok := scope[name.Lexeme]; ok {
+			return a.newErrorTk(fmt.Sprintf("'%s' is already declared in this function or event.", name.Lexeme), name)
+		}
+	}
+	if a.currentFunction != nil {
+		for _, p := range a.currentFunction.Params {
+			if p.Name.Lexeme == name.Lexeme {
+				return a.newErrorTk(fmt.Sprintf("'%s' is already declared as a parameter.", name.Lexeme), name)
+			}
+		}
+	}
+	return nil
+}
+
+// declareLocal adds name to the innermost scope and returns the name of the backing variable.
+func (a *analyzer) declareLocal(name parser.Token) parser.Token {
+	backing := a.uniqueHiddenName(name.Lexeme)
+	a.scopes[len(a.scopes)-1][name.Lexeme] = backing
+	name.Lexeme = backing
+	return name
+}
+
+// uniqueHiddenName returns an unused variable name for name in the current function or event.
+func (a *analyzer) uniqueHiddenName(name string) string {
+	hidden := fmt.Sprintf("$%s.%s", a.scopeName, name)
+	for i := 2; a.variables[hidden] != nil || a.lists[hidden] != nil || a.recordVariables[hidden] != nil; i++ {
+		hidden = fmt.Sprintf("$%s.%s%d", a.scopeName, name, i)
+	}
+	return hidden
+}
+
+func (a *analyzer) undeclareLocal(name parser.Token) {
+	if len(a.scopes) > 0 {
+		delete(a.scopes[len(a.scopes)-1], name.Lexeme)
+	}
+}
+
+// resolveLocal replaces the name of a local variable with the name of its backing variable.
+func (a *analyzer) resolveLocal(name parser.Token) parser.Token {
+	for i := len(a.scopes) - 1; i >= 0; i-- {
+		if backing, ok := a.scopes[i][name.Lexeme]; ok {
+			name.Lexeme = backing
+			return name
+		}
+	}
+	return name
+}
+
+func (a *analyzer) addInitializers(local bool, stmts []parser.Stmt) {
+	if local {
+		// local initializers are executed in place of the declaration
+		a.hoisted = stmts
+	} else {
+		a.variableInitializers = append(a.variableInitializers, stmts...)
+	}
+}
+
 func (a *analyzer) VisitCall(stmt *parser.StmtCall) error {
 	if a.unreachable {
 		a.newWarningStmt("Unreachable code.", stmt)
 	}
 
+	if _, ok := a.events[stmt.Name.Lexeme]; !ok && stmt.Await.Type == parser.TkAwait {
+		return a.newErrorTk("Only custom events can be awaited.", stmt.Await)
+	}
+
+	if r := a.recordListCall(stmt); r != nil {
+		return a.callRecordList(stmt, r)
+	}
+
+	if err := a.checkListChange(stmt); err != nil {
+		return err
+	}
+
+	stmt.Name.Lexeme = a.requireHelper(stmt.Name.Lexeme, stmt.Parameters)
 	if f, ok := a.functions[stmt.Name.Lexeme]; ok {
 		f.used = true
 
@@ -517,6 +881,15 @@ func (a *analyzer) VisitCall(stmt *parser.StmtCall) error {
 			return a.newErrorStmt("Wrong argument count.", stmt)
 		}
 
+		if f.template != nil {
+			var err error
+			f, stmt.Parameters, err = a.specialize(f, stmt.Parameters)
+			if err != nil {
+				return err
+			}
+			stmt.Name.Lexeme = f.Name.Lexeme
+		}
+
 		var err error
 		for i, p := range stmt.Parameters {
 			err = p.Accept(a)
@@ -526,7 +899,9 @@ func (a *analyzer) VisitCall(stmt *parser.StmtCall) error {
 			}
 			if p.Type() != f.Params[i].Type.DataType {
 				a.errors = append(a.errors, a.newErrorExpr(fmt.Sprintf("Expected %s parameter '%s'.", f.Params[i].Type.DataType, f.Params[i].Name.Lexeme), p))
+				continue
 			}
+			stmt.Parameters[i] = a.hoistCalls(p)
 		}
 	} else if fn, ok := FuncCalls[stmt.Name.Lexeme]; ok {
 		types := make([]string, len(stmt.Parameters))
@@ -569,12 +944,45 @@ func (a *analyzer) VisitCall(stmt *parser.StmtCall) error {
 				}
 				return a.newErrorStmt(fmt.Sprintf("Invalid arguments:\n  have: (%s)\n  want: %s", strings.Join(types, ", "), strings.Join(signatures, " or ")), stmt)
 			}
+			if err := a.checkTableArg(stmt.Name.Lexeme, stmt.Parameters); err != nil {
+				return err
+			}
+			for i, p := range stmt.Parameters {
+				stmt.Parameters[i] = a.hoistCalls(p)
+			}
 		}
 	} else if ev, ok := a.events[stmt.Name.Lexeme]; ok {
 		ev.triggered = true
-		if len(stmt.Parameters) > 0 {
+		if len(ev.Params) == 0 && len(stmt.Parameters) > 0 {
 			return a.newErrorStmt("Events don't take any arguments.", stmt)
 		}
+		if len(stmt.Parameters) != len(ev.Params) {
+			return a.newErrorStmt("Wrong argument count.", stmt)
+		}
+
+		var hadError bool
+		for i, p := range stmt.Parameters {
+			err := p.Accept(a)
+			if err != nil {
+				a.errors = append(a.errors, err)
+				hadError = true
+				continue
+			}
+			if p.Type() != ev.Params[i].Type.DataType {
+				a.errors = append(a.errors, a.newErrorExpr(fmt.Sprintf("Expected %s parameter '%s'.", ev.Params[i].Type.DataType, ev.Params[i].Name.Lexeme), p))
+				hadError = true
+				continue
+			}
+			stmt.Parameters[i] = a.hoistCalls(p)
+		}
+
+		// The payload is passed through hidden variables which are set immediately before the broadcast.
+		if !hadError {
+			for i, p := range stmt.Parameters {
+				a.hoisted = append(a.hoisted, newAssignStmt(a.hiddenToken(ev.Payload[i].Name.Lexeme, stmt.Name), p))
+			}
+		}
+		stmt.Parameters = nil
 	} else {
 		if _, ok := ExprFuncCalls[stmt.Name.Lexeme]; ok {
 			return a.newErrorStmt("Only functions which don't return a value are allowed in this context.", stmt)
@@ -594,6 +1002,11 @@ func (a *analyzer) VisitAssignment(stmt *parser.StmtAssignment) error {
 		a.newWarningStmt("Unreachable code.", stmt)
 	}
 
+	if r := a.recordVariable(&parser.ExprIdentifier{Name: stmt.Variable}); r != nil {
+		return a.assignRecord(stmt, r)
+	}
+
+	stmt.Variable = a.resolveLocal(stmt.Variable)
 	if assignment, ok := Assignments[stmt.Variable.Lexeme]; ok {
 		err := stmt.Value.Accept(a)
 		if err != nil {
@@ -602,6 +1015,7 @@ func (a *analyzer) VisitAssignment(stmt *parser.StmtAssignment) error {
 		if stmt.Value.Type() != assignment.DataType {
 			return a.newErrorExpr(fmt.Sprintf("Cannot assign %s value to %s variable.", stmt.Value.Type(), assignment.DataType), stmt.Value)
 		}
+		stmt.Value = a.hoistCalls(stmt.Value)
 	} else {
 		v, ok := a.variables[stmt.Variable.Lexeme]
 		if !ok {
@@ -620,6 +1034,18 @@ func (a *analyzer) VisitAssignment(stmt *parser.StmtAssignment) error {
 		if v.DataType != "" && stmt.Value.Type() != v.DataType {
 			return a.newErrorExpr(fmt.Sprintf("Cannot assign %s value to %s variable.", stmt.Value.Type(), v.DataType), stmt.Value)
 		}
+		if v.DataType == parser.DTBool && stmt.Operator.Type != parser.TkAssign {
+			return a.newErrorTk("Boolean variables can only be assigned with '='.", stmt.Operator)
+		}
+		if v.enum != nil {
+			if stmt.Operator.Type != parser.TkAssign {
+				return a.newErrorTk("Enum variables can only be assigned with '='.", stmt.Operator)
+			}
+			if a.enumOf(stmt.Value) != v.enum {
+				return a.newErrorExpr(fmt.Sprintf("Expected a %s value.", v.enum.Name.Lexeme), stmt.Value)
+			}
+		}
+		stmt.Value = a.hoistCalls(stmt.Value)
 	}
 	return nil
 }
@@ -629,6 +1055,10 @@ func (a *analyzer) VisitIf(stmt *parser.StmtIf) error {
 		a.newWarningStmt("Unreachable code.", stmt)
 	}
 
+	if stmt.Keyword.Type == parser.TkIf {
+		a.checkEnumChain(stmt)
+	}
+
 	err := stmt.Condition.Accept(a)
 	if err != nil {
 		return err
@@ -636,9 +1066,10 @@ func (a *analyzer) VisitIf(stmt *parser.StmtIf) error {
 	if stmt.Condition.Type() != parser.DTBool {
 		return a.newErrorExpr("Expected boolean condition.", stmt.Condition)
 	}
+	stmt.Condition = a.hoistCalls(stmt.Condition)
 
-	a.visitBody(stmt.Body)
-	a.visitBody(stmt.ElseBody)
+	stmt.Body = a.visitBody(stmt.Body)
+	stmt.ElseBody = a.visitBody(stmt.ElseBody)
 	return nil
 }
 
@@ -646,6 +1077,21 @@ func (a *analyzer) VisitLoop(stmt *parser.StmtLoop) error {
 	if a.unreachable {
 		a.newWarningStmt("Unreachable code.", stmt)
 	}
+	isWhile := stmt.Keyword.Type == parser.TkWhile && stmt.Condition != nil
+
+	var prologue, epilogue []parser.Stmt
+	if stmt.Variable.Type == parser.TkIdentifier {
+		a.scopes = append(a.scopes, make(map[string]string))
+		defer func() {
+			a.scopes = a.scopes[:len(a.scopes)-1]
+		}()
+		var err error
+		prologue, epilogue, err = a.lowerForIn(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
 	forever := stmt.Condition == nil
 	if !forever {
 		switch stmt.Keyword.Type {
@@ -655,6 +1101,8 @@ func (a *analyzer) VisitLoop(stmt *parser.StmtLoop) error {
 				a.errors = append(a.errors, err)
 			} else if stmt.Condition.Type() != parser.DTBool {
 				a.errors = append(a.errors, a.newErrorExpr("Expected boolean condition.", stmt.Condition))
+			} else {
+				stmt.Condition = a.hoistCalls(stmt.Condition)
 			}
 		case parser.TkFor:
 			err := stmt.Condition.Accept(a)
@@ -662,19 +1110,96 @@ func (a *analyzer) VisitLoop(stmt *parser.StmtLoop) error {
 				a.errors = append(a.errors, err)
 			} else if stmt.Condition.Type() != parser.DTNumber {
 				return a.newErrorExpr("Expected number.", stmt.Condition)
+			} else {
+				stmt.Condition = a.hoistCalls(stmt.Condition)
 			}
 		default:
 			a.errors = append(a.errors, a.newErrorTk("Unknown loop type.", stmt.Keyword))
 		}
 	}
-	a.visitBody(stmt.Body)
-	if forever {
+	conditionCalls := a.hoisted
+
+	a.loops = append(a.loops, &loop{})
+	stmt.Body = a.visitBody(stmt.Body)
+	l := a.loops[len(a.loops)-1]
+	a.loops = a.loops[:len(a.loops)-1]
+
+	if l.flag != nil {
+		a.lowerLoopControl(stmt, l)
+	}
+	if len(prologue) > 0 || len(epilogue) > 0 {
+		stmt.Body = append(append(prologue, stmt.Body...), epilogue...)
+	}
+
+	if isWhile && len(conditionCalls) > 0 {
+		if l.hasBreak {
+			stmt.Body = append(stmt.Body, &parser.StmtIf{
+				Keyword: stmt.Keyword,
+				Condition: &parser.ExprUnary{
+					Operator: parser.Token{
+						Type: parser.TkBang,
+					},
+					Right:      l.flagEquals(loopBreak),
+					ReturnType: parser.DTBool,
+				},
+				Body: conditionCalls,
+			})
+		} else {
+			stmt.Body = append(stmt.Body, conditionCalls...)
+		}
+	}
+	if forever && !l.hasBreak {
 		a.unreachable = true
 	}
 	return nil
 }
 
+func (a *analyzer) VisitReturn(stmt *parser.StmtReturn) error {
+	if a.unreachable {
+		a.newWarningStmt("Unreachable code.", stmt)
+	}
+	a.unreachable = true
+
+	fn := a.currentFunction
+	if fn == nil {
+		return a.newErrorStmt("Return statements are only allowed inside of functions.", stmt)
+	}
+
+	if fn.ReturnVariable == nil {
+		if stmt.Value != nil {
+			return a.newErrorExpr("This function does not return a value.", stmt.Value)
+		}
+		return nil
+	}
+
+	if stmt.Value == nil {
+		return a.newErrorStmt(fmt.Sprintf("Expected return value of type %s.", fn.ReturnType), stmt)
+	}
+	err := stmt.Value.Accept(a)
+	if err != nil {
+		return err
+	}
+	if stmt.Value.Type() != fn.ReturnType {
+		return a.newErrorExpr(fmt.Sprintf("Wrong data type. Expected %s.", fn.ReturnType), stmt.Value)
+	}
+
+	value := a.hoistCalls(stmt.Value)
+	a.hoisted = append(a.hoisted, &parser.StmtAssignment{
+		Variable: a.hiddenToken(fn.ReturnVariable.Name.Lexeme, stmt.Keyword),
+		Operator: parser.Token{
+			Type: parser.TkAssign,
+		},
+		Value: value,
+	})
+	stmt.Value = value
+	return nil
+}
+
 func (a *analyzer) VisitIdentifier(expr *parser.ExprIdentifier) error {
+	if _, ok := a.recordVariables[a.resolveLocal(expr.Name).Lexeme]; ok {
+		return a.newErrorTk("Records cannot be used as values. Use one of their fields instead.", expr.Name)
+	}
+	expr.Name = a.resolveLocal(expr.Name)
 	if a.currentFunction != nil {
 		for _, p := range a.currentFunction.Params {
 			if p.Name.Lexeme == expr.Name.Lexeme {
@@ -718,6 +1243,51 @@ func (a *analyzer) VisitIdentifier(expr *parser.ExprIdentifier) error {
 }
 
 func (a *analyzer) VisitExprFuncCall(expr *parser.ExprFuncCall) error {
+	if a.isHelperCall(expr) {
+		expr.Name.Lexeme = a.requireHelper(expr.Name.Lexeme, expr.Parameters)
+	}
+	if f, ok := a.functions[expr.Name.Lexeme]; ok && !a.isConstantExpr(expr) {
+		if f.ReturnType == "" {
+			return a.newErrorExpr("Only functions which return a value are allowed in this context.", expr)
+		}
+		f.used = true
+		expr.ReturnType = f.ReturnType
+
+		if len(expr.Parameters) != len(f.Params) {
+			return a.newErrorExpr("Wrong argument count.", expr)
+		}
+
+		if f.template != nil {
+			var err error
+			f, expr.Parameters, err = a.specialize(f, expr.Parameters)
+			if err != nil {
+				return err
+			}
+			expr.Name.Lexeme = f.Name.Lexeme
+			if f.ReturnVariable == nil {
+				return a.newErrorExpr("Only functions which return a value are allowed in this context.", expr)
+			}
+		}
+		for i, p := range expr.Parameters {
+			err := p.Accept(a)
+			if err != nil {
+				a.errors = append(a.errors, err)
+				continue
+			}
+			if p.Type() != f.Params[i].Type.DataType {
+				a.errors = append(a.errors, a.newErrorExpr(fmt.Sprintf("Expected %s parameter '%s'.", f.Params[i].Type.DataType, f.Params[i].Name.Lexeme), p))
+			}
+		}
+		return nil
+	}
+
+	if expr.Name.Lexeme == "lists.length" && len(expr.Parameters) == 1 {
+		if r := a.recordVariable(expr.Parameters[0]); r != nil && r.list {
+			// all field lists have the same length
+			expr.Parameters[0] = a.recordField(r.fields[0], expr.Parameters[0])
+		}
+	}
+
 	fn, ok := ExprFuncCalls[expr.Name.Lexeme]
 	if !ok {
 		if _, ok := FuncCalls[expr.Name.Lexeme]; ok {
@@ -766,6 +1336,9 @@ func (a *analyzer) VisitExprFuncCall(expr *parser.ExprFuncCall) error {
 			}
 			return a.newErrorExpr(fmt.Sprintf("Invalid arguments:\n  have: (%s)\n  want: %s", strings.Join(types, ", "), strings.Join(signatures, " or ")), expr)
 		}
+		if err := a.checkTableArg(expr.Name.Lexeme, expr.Parameters); err != nil {
+			return err
+		}
 	} else {
 		expr.ReturnType = fn.Signatures[0].ReturnType
 	}
@@ -895,15 +1468,144 @@ func (a *analyzer) VisitGrouping(expr *parser.ExprGrouping) error {
 	return expr.Expr.Accept(a)
 }
 
-func (a *analyzer) visitBody(body []parser.Stmt) {
+func (a *analyzer) VisitRange(expr *parser.ExprRange) error {
+	return a.newErrorExpr("Ranges are only allowed in for loops.", expr)
+}
+
+func (a *analyzer) VisitTernary(expr *parser.ExprTernary) error {
+	err := expr.Condition.Accept(a)
+	if err != nil {
+		a.errors = append(a.errors, err)
+	} else if expr.Condition.Type() != parser.DTBool {
+		a.errors = append(a.errors, a.newErrorExpr("Expected boolean condition.", expr.Condition))
+	}
+
+	err = expr.TrueValue.Accept(a)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(string(expr.TrueValue.Type()), "[]") {
+		return a.newErrorExpr("Lists are not allowed in conditional expressions.", expr.TrueValue)
+	}
+
+	err = expr.FalseValue.Accept(a)
+	if err != nil {
+		return err
+	}
+	if expr.FalseValue.Type() != expr.TrueValue.Type() {
+		return a.newErrorExpr(fmt.Sprintf("Wrong data type. Expected %s.", expr.TrueValue.Type()), expr.FalseValue)
+	}
+
+	expr.ReturnType = expr.TrueValue.Type()
+	return nil
+}
+
+func (a *analyzer) VisitInterpolation(expr *parser.ExprInterpolation) error {
+	for _, p := range expr.Parts {
+		err := p.Accept(a)
+		if err != nil {
+			a.errors = append(a.errors, err)
+			continue
+		}
+		if p.Type() == parser.DTImage || strings.HasSuffix(string(p.Type()), "[]") {
+			a.errors = append(a.errors, a.newErrorExpr("Only numbers, strings and booleans can be inserted into strings.", p))
+		}
+	}
+	return nil
+}
+
+func (a *analyzer) visitBody(body []parser.Stmt) []parser.Stmt {
+	if body == nil {
+		return nil
+	}
+
 	unreachable := a.unreachable
+	hoisted := a.hoisted
+	pendingReads := a.pendingReads
+	tempCounts := a.tempCounts
+
+	a.scopes = append(a.scopes, make(map[string]string))
+
+	newBody := make([]parser.Stmt, 0, len(body))
 	for _, s := range body {
+		a.beginStatement()
 		err := s.Accept(a)
 		if err != nil {
 			a.errors = append(a.errors, err)
 		}
+		newBody = append(newBody, a.hoisted...)
+		if a.replaceStatement {
+			continue
+		}
+		switch s.(type) {
+		case *parser.StmtVarDecl, *parser.StmtLoopControl, *parser.StmtMatch:
+			// already replaced by the hoisted statements
+		default:
+			newBody = append(newBody, s)
+		}
 	}
+
+	a.scopes = a.scopes[:len(a.scopes)-1]
 	a.unreachable = unreachable
+	a.hoisted = hoisted
+	a.pendingReads = pendingReads
+	a.tempCounts = tempCounts
+	return newBody
+}
+
+// assignedVariables returns the names of all variables which are assigned to in body.
+func assignedVariables(body []parser.Stmt) map[string]bool {
+	assigned := make(map[string]bool)
+	for _, s := range body {
+		switch stmt := s.(type) {
+		case *parser.StmtAssignment:
+			assigned[stmt.Variable.Lexeme] = true
+		case *parser.StmtIf:
+			for name := range assignedVariables(stmt.Body) {
+				assigned[name] = true
+			}
+			for name := range assignedVariables(stmt.ElseBody) {
+				assigned[name] = true
+			}
+		case *parser.StmtLoop:
+			for name := range assignedVariables(stmt.Body) {
+				assigned[name] = true
+			}
+		case *parser.StmtMatch:
+			for _, c := range stmt.Cases {
+				for name := range assignedVariables(c.Body) {
+					assigned[name] = true
+				}
+			}
+			for name := range assignedVariables(stmt.ElseBody) {
+				assigned[name] = true
+			}
+		}
+	}
+	return assigned
+}
+
+// alwaysReturns reports whether the execution of body can never reach its end.
+func alwaysReturns(body []parser.Stmt) bool {
+	for _, s := range body {
+		switch stmt := s.(type) {
+		case *parser.StmtReturn:
+			return true
+		case *parser.StmtIf:
+			if alwaysReturns(stmt.Body) && alwaysReturns(stmt.ElseBody) {
+				return true
+			}
+		case *parser.StmtLoop:
+			if stmt.Condition == nil {
+				return true
+			}
+		case *parser.StmtCall:
+			if stmt.Name.Lexeme == "script.stop" || stmt.Name.Lexeme == "script.stopAll" {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 type AnalyzerError struct {
@@ -921,12 +1623,22 @@ func (e AnalyzerError) Error() string {
 	}
 }
 
-func (a *analyzer) newErrorTk(message string, token parser.Token) error {
+// tokenEnd returns the end position of token.
+// Tokens from the source code keep their original span even if their lexeme was renamed.
+func tokenEnd(token parser.Token) parser.Position {
+	if token.EndPos.Line == token.Pos.Line && token.EndPos.Column >= token.Pos.Column && token.EndPos.Path == token.Pos.Path {
+		return token.EndPos
+	}
 	end := token.Pos
 	end.Column += len(token.Lexeme) - 1
 	if token.Type == parser.TkNewLine {
 		end.Column += 1
 	}
+	return end
+}
+
+func (a *analyzer) newErrorTk(message string, token parser.Token) error {
+	end := tokenEnd(token)
 	return AnalyzerError{
 		Start:   token.Pos,
 		End:     end,
@@ -953,11 +1665,7 @@ func (a *analyzer) newErrorStmt(message string, stmt parser.Stmt) error {
 }
 
 func (a *analyzer) newWarningTk(message string, token parser.Token) {
-	end := token.Pos
-	end.Column += len(token.Lexeme) - 1
-	if token.Type == parser.TkNewLine {
-		end.Column += 1
-	}
+	end := tokenEnd(token)
 	a.warnings = append(a.warnings, AnalyzerError{
 		Start:   token.Pos,
 		End:     end,
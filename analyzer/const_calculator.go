@@ -85,6 +85,14 @@ func (c *constCalculator) VisitExprFuncCall(expr *parser.ExprFuncCall) error {
 			constParams = false
 		}
 	}
+	if c.listArg(expr.Parameters) != nil {
+		folded, err := c.foldListCall(expr)
+		if err != nil {
+			return err
+		}
+		c.newExpr = folded
+		return nil
+	}
 	if !constParams {
 		c.newExpr = expr
 		return nil
@@ -122,6 +130,44 @@ func (c *constCalculator) VisitExprFuncCall(expr *parser.ExprFuncCall) error {
 		value = math.Pow(math.E, expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64))
 	case "math.tenPowerOf":
 		value = math.Pow(10, expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64))
+	case "math.pow":
+		value = math.Pow(expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64), expr.Parameters[1].(*parser.ExprLiteral).Token.Literal.(float64))
+	case "math.min":
+		value = math.Min(expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64), expr.Parameters[1].(*parser.ExprLiteral).Token.Literal.(float64))
+	case "math.max":
+		value = math.Max(expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64), expr.Parameters[1].(*parser.ExprLiteral).Token.Literal.(float64))
+	case "math.clamp":
+		n := expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64)
+		min := expr.Parameters[1].(*parser.ExprLiteral).Token.Literal.(float64)
+		max := expr.Parameters[2].(*parser.ExprLiteral).Token.Literal.(float64)
+		if n < min {
+			value = min
+		} else if n > max {
+			value = max
+		} else {
+			value = n
+		}
+	case "math.map":
+		n := expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64)
+		inMin := expr.Parameters[1].(*parser.ExprLiteral).Token.Literal.(float64)
+		inMax := expr.Parameters[2].(*parser.ExprLiteral).Token.Literal.(float64)
+		outMin := expr.Parameters[3].(*parser.ExprLiteral).Token.Literal.(float64)
+		outMax := expr.Parameters[4].(*parser.ExprLiteral).Token.Literal.(float64)
+		if inMin == inMax {
+			return c.newErrorExpr("Cannot map from an empty range.", expr)
+		}
+		value = outMin + (n-inMin)*(outMax-outMin)/(inMax-inMin)
+	case "math.atan2":
+		value = math.Atan2(expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64), expr.Parameters[1].(*parser.ExprLiteral).Token.Literal.(float64)) * 180 / math.Pi
+	case "math.sign":
+		n := expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(float64)
+		if n > 0 {
+			value = 1.0
+		} else if n < 0 {
+			value = -1.0
+		} else {
+			value = 0.0
+		}
 
 	case "strings.length":
 		value = float64(utf8.RuneCountInString(expr.Parameters[0].(*parser.ExprLiteral).Token.Literal.(string)))
@@ -137,8 +183,15 @@ func (c *constCalculator) VisitExprFuncCall(expr *parser.ExprFuncCall) error {
 		value = string(str[index-1])
 
 	default:
-		c.newExpr = expr
-		return nil
+		params := make([]any, len(expr.Parameters))
+		for i, p := range expr.Parameters {
+			params[i] = p.(*parser.ExprLiteral).Token.Literal
+		}
+		var ok bool
+		if value, ok = foldStringFunc(expr.Name.Lexeme, params); !ok {
+			c.newExpr = expr
+			return nil
+		}
 	}
 	c.newExpr = c.newLiteral(value, expr)
 	return nil
@@ -279,6 +332,8 @@ func (c *constCalculator) VisitBinary(expr *parser.ExprBinary) error {
 					return c.newErrorExpr("Cannot divide by zero.", expr.Right)
 				}
 				value = math.Mod(ll.Token.Literal.(float64), lr.Token.Literal.(float64))
+			case parser.TkPower:
+				value = math.Pow(ll.Token.Literal.(float64), lr.Token.Literal.(float64))
 			default:
 				c.newExpr = expr
 				return nil
@@ -305,6 +360,81 @@ func (c *constCalculator) VisitGrouping(expr *parser.ExprGrouping) error {
 	return nil
 }
 
+func (c *constCalculator) VisitMatch(stmt *parser.StmtMatch) error {
+	panic("Should never be called.")
+}
+
+func (c *constCalculator) VisitImport(stmt *parser.StmtImport) error {
+	panic("Should never be called.")
+}
+
+func (c *constCalculator) VisitRange(expr *parser.ExprRange) error {
+	panic("Should never be called.")
+}
+
+func (c *constCalculator) VisitInterpolation(expr *parser.ExprInterpolation) error {
+	parts := make([]parser.Expr, 0, len(expr.Parts))
+	for _, p := range expr.Parts {
+		err := p.Accept(c)
+		if err != nil {
+			return err
+		}
+		p = c.newExpr
+
+		// merge adjacent literals
+		if l, ok := p.(*parser.ExprLiteral); ok && len(parts) > 0 {
+			if prev, ok := parts[len(parts)-1].(*parser.ExprLiteral); ok {
+				merged := c.newLiteral(fmt.Sprintf("%v%v", prev.Token.Literal, l.Token.Literal), expr).(*parser.ExprLiteral)
+				merged.Token.Pos, _ = prev.Position()
+				merged.Token.LineAfterInclude = merged.Token.Pos.Line
+				_, merged.End = l.Position()
+				merged.Token.EndPos = merged.End
+				parts[len(parts)-1] = merged
+				continue
+			}
+		}
+		parts = append(parts, p)
+	}
+	expr.Parts = parts
+
+	if len(parts) == 1 {
+		if l, ok := parts[0].(*parser.ExprLiteral); ok {
+			c.newExpr = c.newLiteral(fmt.Sprintf("%v", l.Token.Literal), expr)
+			return nil
+		}
+	}
+	c.newExpr = expr
+	return nil
+}
+
+func (c *constCalculator) VisitTernary(expr *parser.ExprTernary) error {
+	err := expr.Condition.Accept(c)
+	if err != nil {
+		return err
+	}
+	expr.Condition = c.newExpr
+	err = expr.TrueValue.Accept(c)
+	if err != nil {
+		return err
+	}
+	expr.TrueValue = c.newExpr
+	err = expr.FalseValue.Accept(c)
+	if err != nil {
+		return err
+	}
+	expr.FalseValue = c.newExpr
+	if l, ok := expr.Condition.(*parser.ExprLiteral); ok {
+		if l.Token.Literal.(bool) {
+			c.newExpr = expr.TrueValue
+		} else {
+			c.newExpr = expr.FalseValue
+		}
+	} else {
+		c.newExpr = expr
+	}
+	return nil
+}
+
 func (c *constCalculator) VisitVarDecl(stmt *parser.StmtVarDecl) error {
 	return nil
 }
@@ -315,7 +445,15 @@ func (c *constCalculator) VisitConstDecl(stmt *parser.StmtConstDecl) error {
 		return err
 	}
 	stmt.Value = c.newExpr
-	if l, ok := stmt.Value.(*parser.ExprLiteral); !ok {
+	if init, ok := stmt.Value.(*parser.ExprListInitializer); ok {
+		values := make([]any, len(init.Values))
+		for i, v := range init.Values {
+			if l, ok := v.(*parser.ExprLiteral); ok {
+				values[i] = l.Token.Literal
+			}
+		}
+		c.definitions.Lists[stmt.Name.Lexeme].values = values
+	} else if l, ok := stmt.Value.(*parser.ExprLiteral); !ok {
 		return c.newErrorExpr("Cannot assign a non-constant value to a constant.", stmt.Value)
 	} else {
 		c.definitions.Constants[stmt.Name.Lexeme].Value = l.Token.Literal
@@ -376,6 +514,14 @@ func (c *constCalculator) VisitEventDecl(stmt *parser.StmtEventDecl) error {
 	return nil
 }
 
+func (c *constCalculator) VisitTypeDecl(stmt *parser.StmtTypeDecl) error {
+	return nil
+}
+
+func (c *constCalculator) VisitEnumDecl(stmt *parser.StmtEnumDecl) error {
+	return nil
+}
+
 func (c *constCalculator) VisitCall(stmt *parser.StmtCall) error {
 	for i, p := range stmt.Parameters {
 		err := p.Accept(c)
@@ -384,6 +530,16 @@ func (c *constCalculator) VisitCall(stmt *parser.StmtCall) error {
 		}
 		stmt.Parameters[i] = c.newExpr
 	}
+	if stmt.Name.Lexeme == "lists.set2d" {
+		if list := c.listArg(stmt.Parameters); list != nil {
+			index, err := c.tableIndex(list, stmt.Name, stmt.Parameters[1], stmt.Parameters[2])
+			if err != nil {
+				return err
+			}
+			stmt.Name.Lexeme = "lists.replace"
+			stmt.Parameters = []parser.Expr{stmt.Parameters[0], index, stmt.Parameters[3]}
+		}
+	}
 	return nil
 }
 
@@ -434,6 +590,14 @@ func (c *constCalculator) VisitLoop(stmt *parser.StmtLoop) error {
 	return nil
 }
 
+func (c *constCalculator) VisitReturn(stmt *parser.StmtReturn) error {
+	return nil
+}
+
+func (c *constCalculator) VisitLoopControl(stmt *parser.StmtLoopControl) error {
+	panic("Should never be called.")
+}
+
 func (c *constCalculator) newErrorTk(message string, token parser.Token) error {
 	end := token.Pos
 	end.Column += len(token.Lexeme) - 1
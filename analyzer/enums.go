@@ -0,0 +1,236 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/juho05/embe/parser"
+)
+
+// The values of an enum are string constants named '<enum>.<value>' whose value is the name of the value.
+// Variables declared with an enum type are string variables which only accept values of their enum.
+
+type Enum struct {
+	Name   parser.Token
+	Values []parser.Token
+}
+
+func (a *analyzer) VisitEnumDecl(stmt *parser.StmtEnumDecl) error {
+	if err := a.assertNotDeclared(stmt.Name); err != nil {
+		return err
+	}
+	if len(stmt.Values) == 0 {
+		return a.newErrorStmt("Enums must have at least one value.", stmt)
+	}
+
+	enum := &Enum{
+		Name:   stmt.Name,
+		Values: make([]parser.Token, 0, len(stmt.Values)),
+	}
+	names := make([]string, 0, len(stmt.Values))
+	for _, v := range stmt.Values {
+		if slices.Contains(names, v.Lexeme) {
+			a.errors = append(a.errors, a.newErrorTk("Duplicate enum value.", v))
+			continue
+		}
+		names = append(names, v.Lexeme)
+		enum.Values = append(enum.Values, v)
+
+		name := v
+		name.Lexeme = fmt.Sprintf("%s.%s", stmt.Name.Lexeme, v.Lexeme)
+		a.constants[name.Lexeme] = &Constant{
+			Name:  name,
+			Value: v.Lexeme,
+			Type:  parser.DTString,
+			enum:  enum,
+		}
+	}
+	a.enums[stmt.Name.Lexeme] = enum
+	return nil
+}
+
+// enumType converts the enum type annotation of stmt to a string annotation and returns the enum.
+// Variables without an initial value start with the first value of the enum.
+func (a *analyzer) enumType(stmt *parser.StmtVarDecl) *Enum {
+	if stmt.Type.Type != parser.TkIdentifier {
+		return nil
+	}
+	list := strings.HasSuffix(string(stmt.DataType), "[]")
+	enum, ok := a.enums[strings.TrimSuffix(string(stmt.DataType), "[]")]
+	if !ok {
+		return nil
+	}
+
+	stmt.Type.Type = parser.TkType
+	stmt.DataType = parser.DTString
+	if list {
+		// lists only store the values, they are not checked
+		stmt.DataType = parser.DTStringList
+		return nil
+	}
+	if stmt.Value == nil {
+		stmt.AssignToken = parser.Token{
+			Type: parser.TkAssign,
+		}
+		stmt.Value = &parser.ExprIdentifier{
+			Name: a.hiddenToken(fmt.Sprintf("%s.%s", enum.Name.Lexeme, enum.Values[0].Lexeme), stmt.Type),
+		}
+	}
+	return enum
+}
+
+// enumOf returns the enum of the values expr evaluates to or nil if expr doesn't evaluate to enum values.
+func (a *analyzer) enumOf(expr parser.Expr) *Enum {
+	switch e := expr.(type) {
+	case *parser.ExprIdentifier:
+		if c := a.enumConstant(e); c != nil {
+			return c.enum
+		}
+		if _, enum := a.enumVariable(e); enum != nil {
+			return enum
+		}
+	case *parser.ExprGrouping:
+		return a.enumOf(e.Expr)
+	case *parser.ExprTernary:
+		if enum := a.enumOf(e.TrueValue); enum == a.enumOf(e.FalseValue) {
+			return enum
+		}
+	}
+	return nil
+}
+
+func (a *analyzer) enumConstant(expr parser.Expr) *Constant {
+	ident, ok := expr.(*parser.ExprIdentifier)
+	if !ok || a.isParameter(ident.Name) {
+		return nil
+	}
+	if c, ok := a.constants[a.resolveLocal(ident.Name).Lexeme]; ok && c.enum != nil {
+		return c
+	}
+	return nil
+}
+
+// enumVariable returns the name of the backing variable and the enum of the enum variable referenced by expr.
+func (a *analyzer) enumVariable(expr parser.Expr) (string, *Enum) {
+	ident, ok := expr.(*parser.ExprIdentifier)
+	if !ok || a.isParameter(ident.Name) {
+		return "", nil
+	}
+	name := a.resolveLocal(ident.Name).Lexeme
+	if v, ok := a.variables[name]; ok && v.enum != nil {
+		return name, v.enum
+	}
+	return "", nil
+}
+
+func (a *analyzer) isParameter(name parser.Token) bool {
+	if a.currentFunction == nil {
+		return false
+	}
+	for _, p := range a.currentFunction.Params {
+		if p.Name.Lexeme == name.Lexeme {
+			return true
+		}
+	}
+	return false
+}
+
+// enumComparison returns the enum variable and the values it is compared with if condition consists only of
+// comparisons of the same enum variable with values of its enum.
+func (a *analyzer) enumComparison(condition parser.Expr) (string, *Enum, []string) {
+	switch e := condition.(type) {
+	case *parser.ExprGrouping:
+		return a.enumComparison(e.Expr)
+	case *parser.ExprBinary:
+		switch e.Operator.Type {
+		case parser.TkOr:
+			leftName, leftEnum, leftValues := a.enumComparison(e.Left)
+			rightName, rightEnum, rightValues := a.enumComparison(e.Right)
+			if leftEnum == nil || leftEnum != rightEnum || leftName != rightName {
+				return "", nil, nil
+			}
+			return leftName, leftEnum, append(leftValues, rightValues...)
+		case parser.TkEqual:
+			name, enum := a.enumVariable(e.Left)
+			value := a.enumConstant(e.Right)
+			if enum == nil {
+				name, enum = a.enumVariable(e.Right)
+				value = a.enumConstant(e.Left)
+			}
+			if enum == nil || value == nil || value.enum != enum {
+				return "", nil, nil
+			}
+			return name, enum, []string{value.Value.(string)}
+		}
+	}
+	return "", nil, nil
+}
+
+// checkEnumChain warns if stmt starts an if/elif chain without an else branch which compares an enum variable with
+// the values of its enum but doesn't handle all of them.
+func (a *analyzer) checkEnumChain(stmt *parser.StmtIf) {
+	var subject string
+	var enum *Enum
+	handled := make([]string, 0)
+	branches := 0
+	for s := stmt; s != nil; {
+		name, e, values := a.enumComparison(s.Condition)
+		if e == nil || (enum != nil && (e != enum || name != subject)) {
+			return
+		}
+		subject, enum = name, e
+		handled = append(handled, values...)
+		branches++
+
+		if len(s.ElseBody) == 0 {
+			break
+		}
+		next, ok := s.ElseBody[0].(*parser.StmtIf)
+		if !ok || len(s.ElseBody) > 1 || next.Keyword.Type != parser.TkElif {
+			// the else branch handles the remaining values
+			return
+		}
+		s = next
+	}
+
+	// a single if statement only checks for specific values
+	if branches > 1 {
+		a.warnUnhandledValues(enum, handled, stmt.Keyword)
+	}
+}
+
+// checkEnumMatch warns if stmt matches an enum variable without an else branch and doesn't handle all values of its enum.
+func (a *analyzer) checkEnumMatch(stmt *parser.StmtMatch) {
+	if stmt.ElseBody != nil {
+		return
+	}
+	_, enum := a.enumVariable(stmt.Subject)
+	if enum == nil {
+		return
+	}
+	handled := make([]string, 0)
+	for _, c := range stmt.Cases {
+		for _, v := range c.Values {
+			value := a.enumConstant(v)
+			if value == nil || value.enum != enum {
+				return
+			}
+			handled = append(handled, value.Value.(string))
+		}
+	}
+	a.warnUnhandledValues(enum, handled, stmt.Keyword)
+}
+
+func (a *analyzer) warnUnhandledValues(enum *Enum, handled []string, keyword parser.Token) {
+	missing := make([]string, 0)
+	for _, v := range enum.Values {
+		if !slices.Contains(handled, v.Lexeme) {
+			missing = append(missing, v.Lexeme)
+		}
+	}
+	if len(missing) > 0 {
+		a.newWarningTk(fmt.Sprintf("Not all values of %s are handled. Missing: %s.", enum.Name.Lexeme, strings.Join(missing, ", ")), keyword)
+	}
+}
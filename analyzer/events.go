@@ -44,4 +44,101 @@ func init() {
 	newEvent("shakeval", &Param{Name: "comparison", Type: parser.DTString})
 	newEvent("timer", &Param{Name: "comparison", Type: parser.DTString})
 	newEvent("receive", &Param{Name: "message", Type: parser.DTString})
+	newEvent("when", &Param{Name: "condition", Type: parser.DTBool})
+	newEvent("every", &Param{Name: "seconds", Type: parser.DTNumber})
+}
+
+// lowerWhen turns '@when condition:' into a launch event with a forever loop which waits until the condition
+// becomes true, executes the body and then waits until the condition becomes false again.
+func (a *analyzer) lowerWhen(stmt *parser.StmtEvent) {
+	wait := func(condition parser.Expr) parser.Stmt {
+		return &parser.StmtCall{
+			Name:       a.hiddenToken("time.wait", stmt.Name),
+			Parameters: []parser.Expr{condition},
+		}
+	}
+
+	body := make([]parser.Stmt, 0, len(stmt.Body)+2)
+	body = append(body, wait(stmt.Parameter))
+	body = append(body, stmt.Body...)
+	body = append(body, wait(&parser.ExprUnary{
+		Operator: parser.Token{
+			Type: parser.TkBang,
+		},
+		Right:      stmt.Parameter,
+		ReturnType: parser.DTBool,
+	}))
+
+	keyword := stmt.At
+	keyword.Type = parser.TkWhile
+	stmt.Body = []parser.Stmt{
+		&parser.StmtLoop{
+			Keyword: keyword,
+			Body:    body,
+		},
+	}
+	stmt.Name.Lexeme = "launch"
+	stmt.Parameter = nil
+}
+
+// lowerEvery turns '@every seconds:' into a launch event with a forever loop which executes the body periodically.
+// The waiting time is calculated with the timer to compensate for the execution time of the body.
+func (a *analyzer) lowerEvery(stmt *parser.StmtEvent) {
+	next := a.hiddenToken(a.uniqueHiddenName("next"), stmt.Name)
+	a.hiddenVariable(next.Lexeme, parser.DTNumber)
+	timer := a.hiddenToken("time.timer", stmt.Name)
+
+	read := func(name parser.Token) parser.Expr {
+		return &parser.ExprIdentifier{
+			Name: name,
+		}
+	}
+	binary := func(left parser.Expr, operator parser.TokenType, right parser.Expr) parser.Expr {
+		return &parser.ExprBinary{
+			Operator: parser.Token{
+				Type: operator,
+			},
+			Left:  left,
+			Right: right,
+		}
+	}
+
+	init := a.visitBody([]parser.Stmt{
+		newAssignStmt(next, read(timer)),
+	})
+
+	epilogue := a.visitBody([]parser.Stmt{
+		&parser.StmtAssignment{
+			Variable: next,
+			Operator: parser.Token{
+				Type: parser.TkPlusAssign,
+			},
+			Value: stmt.Parameter,
+		},
+		// skip missed periods if the body took too long or the timer was reset
+		&parser.StmtIf{
+			Keyword: stmt.Name,
+			Condition: binary(
+				binary(read(timer), parser.TkGreater, read(next)),
+				parser.TkOr,
+				binary(binary(read(next), parser.TkMinus, read(timer)), parser.TkGreater, stmt.Parameter),
+			),
+			Body: []parser.Stmt{
+				newAssignStmt(next, read(timer)),
+			},
+		},
+		&parser.StmtCall{
+			Name:       a.hiddenToken("time.wait", stmt.Name),
+			Parameters: []parser.Expr{binary(read(next), parser.TkMinus, read(timer))},
+		},
+	})
+
+	keyword := stmt.At
+	keyword.Type = parser.TkWhile
+	stmt.Body = append(init, &parser.StmtLoop{
+		Keyword: keyword,
+		Body:    append(stmt.Body, epilogue...),
+	})
+	stmt.Name.Lexeme = "launch"
+	stmt.Parameter = nil
 }
@@ -79,15 +79,37 @@ func init() {
 	newExprFuncCall("math.log", Signature{Params: []Param{{Name: "n", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
 	newExprFuncCall("math.ePowerOf", Signature{Params: []Param{{Name: "n", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
 	newExprFuncCall("math.tenPowerOf", Signature{Params: []Param{{Name: "n", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("math.pow", Signature{Params: []Param{{Name: "base", Type: parser.DTNumber}, {Name: "exponent", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("math.min", Signature{Params: []Param{{Name: "a", Type: parser.DTNumber}, {Name: "b", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("math.max", Signature{Params: []Param{{Name: "a", Type: parser.DTNumber}, {Name: "b", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("math.clamp", Signature{Params: []Param{{Name: "n", Type: parser.DTNumber}, {Name: "min", Type: parser.DTNumber}, {Name: "max", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("math.map", Signature{Params: []Param{{Name: "n", Type: parser.DTNumber}, {Name: "inMin", Type: parser.DTNumber}, {Name: "inMax", Type: parser.DTNumber}, {Name: "outMin", Type: parser.DTNumber}, {Name: "outMax", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("math.sign", Signature{Params: []Param{{Name: "n", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("math.atan2", Signature{Params: []Param{{Name: "y", Type: parser.DTNumber}, {Name: "x", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
 
 	newExprFuncCall("strings.length", Signature{Params: []Param{{Name: "str", Type: parser.DTString}}, ReturnType: parser.DTNumber})
 	newExprFuncCall("strings.letter", Signature{Params: []Param{{Name: "str", Type: parser.DTString}, {Name: "index", Type: parser.DTNumber}}, ReturnType: parser.DTString})
 	newExprFuncCall("strings.contains", Signature{Params: []Param{{Name: "str", Type: parser.DTString}, {Name: "substr", Type: parser.DTString}}, ReturnType: parser.DTBool})
-
-	newExprFuncCall("lists.get", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}, {Name: "index", Type: parser.DTNumber}}, ReturnType: parser.DTString}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "index", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
-	newExprFuncCall("lists.indexOf", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}, {Name: "value", Type: parser.DTString}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "value", Type: parser.DTNumber}}, ReturnType: parser.DTNumber})
-	newExprFuncCall("lists.length", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}}, ReturnType: parser.DTNumber})
-	newExprFuncCall("lists.contains", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}, {Name: "value", Type: parser.DTString}}, ReturnType: parser.DTBool}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "value", Type: parser.DTNumber}}, ReturnType: parser.DTBool})
+	newExprFuncCall("strings.substring", Signature{Params: []Param{{Name: "str", Type: parser.DTString}, {Name: "from", Type: parser.DTNumber}, {Name: "to", Type: parser.DTNumber}}, ReturnType: parser.DTString})
+	newExprFuncCall("strings.indexOf", Signature{Params: []Param{{Name: "str", Type: parser.DTString}, {Name: "substr", Type: parser.DTString}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("strings.replace", Signature{Params: []Param{{Name: "str", Type: parser.DTString}, {Name: "old", Type: parser.DTString}, {Name: "replacement", Type: parser.DTString}}, ReturnType: parser.DTString})
+	newExprFuncCall("strings.toUpper", Signature{Params: []Param{{Name: "str", Type: parser.DTString}}, ReturnType: parser.DTString})
+	newExprFuncCall("strings.toLower", Signature{Params: []Param{{Name: "str", Type: parser.DTString}}, ReturnType: parser.DTString})
+	newExprFuncCall("strings.trim", Signature{Params: []Param{{Name: "str", Type: parser.DTString}}, ReturnType: parser.DTString})
+	newExprFuncCall("strings.repeat", Signature{Params: []Param{{Name: "str", Type: parser.DTString}, {Name: "count", Type: parser.DTNumber}}, ReturnType: parser.DTString})
+	newExprFuncCall("strings.toFixed", Signature{Params: []Param{{Name: "n", Type: parser.DTNumber}, {Name: "decimals", Type: parser.DTNumber}}, ReturnType: parser.DTString})
+
+	newExprFuncCall("lists.get", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}, {Name: "index", Type: parser.DTNumber}}, ReturnType: parser.DTString}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "index", Type: parser.DTNumber}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "index", Type: parser.DTNumber}}, ReturnType: parser.DTBool})
+	newExprFuncCall("lists.indexOf", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}, {Name: "value", Type: parser.DTString}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "value", Type: parser.DTNumber}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "value", Type: parser.DTBool}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("lists.length", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTBoolList}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("lists.contains", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}, {Name: "value", Type: parser.DTString}}, ReturnType: parser.DTBool}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "value", Type: parser.DTNumber}}, ReturnType: parser.DTBool}, Signature{Params: []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "value", Type: parser.DTBool}}, ReturnType: parser.DTBool})
+	newExprFuncCall("lists.get2d", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}, {Name: "row", Type: parser.DTNumber}, {Name: "column", Type: parser.DTNumber}}, ReturnType: parser.DTString}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "row", Type: parser.DTNumber}, {Name: "column", Type: parser.DTNumber}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "row", Type: parser.DTNumber}, {Name: "column", Type: parser.DTNumber}}, ReturnType: parser.DTBool})
+	newExprFuncCall("lists.rows", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTBoolList}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("lists.columns", Signature{Params: []Param{{Name: "list", Type: parser.DTStringList}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}}, ReturnType: parser.DTNumber}, Signature{Params: []Param{{Name: "list", Type: parser.DTBoolList}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("lists.sum", Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("lists.min", Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("lists.max", Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}}, ReturnType: parser.DTNumber})
+	newExprFuncCall("lists.average", Signature{Params: []Param{{Name: "list", Type: parser.DTNumberList}}, ReturnType: parser.DTNumber})
 
 	newExprFuncCall("display.pixelIsColor", Signature{Params: []Param{{Name: "x", Type: parser.DTNumber}, {Name: "y", Type: parser.DTNumber}, {Name: "r", Type: parser.DTNumber}, {Name: "g", Type: parser.DTNumber}, {Name: "b", Type: parser.DTNumber}}, ReturnType: parser.DTBool})
 	newExprFuncCall("sprite.touchesSprite", Signature{Params: []Param{{Name: "sprite", Type: parser.DTImage}, {Name: "other", Type: parser.DTImage}}, ReturnType: parser.DTBool})
@@ -164,11 +164,19 @@ func init() {
 	newFuncCall("script.stopAll")
 	newFuncCall("script.stopOther")
 
-	newFuncCall("lists.append", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "value", Type: parser.DTString}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "value", Type: parser.DTNumber}})
-	newFuncCall("lists.remove", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "index", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "index", Type: parser.DTNumber}})
-	newFuncCall("lists.clear", []Param{{Name: "list", Type: parser.DTStringList}}, []Param{{Name: "list", Type: parser.DTNumberList}})
-	newFuncCall("lists.insert", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTString}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTNumber}})
-	newFuncCall("lists.replace", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTString}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTNumber}})
+	newFuncCall("lists.append", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "value", Type: parser.DTString}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "value", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "value", Type: parser.DTBool}})
+	newFuncCall("lists.remove", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "index", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "index", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "index", Type: parser.DTNumber}})
+	newFuncCall("lists.clear", []Param{{Name: "list", Type: parser.DTStringList}}, []Param{{Name: "list", Type: parser.DTNumberList}}, []Param{{Name: "list", Type: parser.DTBoolList}})
+	newFuncCall("lists.insert", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTString}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTBool}})
+	newFuncCall("lists.replace", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTString}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "index", Type: parser.DTNumber}, {Name: "value", Type: parser.DTBool}})
+
+	newFuncCall("lists.set2d", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "row", Type: parser.DTNumber}, {Name: "column", Type: parser.DTNumber}, {Name: "value", Type: parser.DTString}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "row", Type: parser.DTNumber}, {Name: "column", Type: parser.DTNumber}, {Name: "value", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "row", Type: parser.DTNumber}, {Name: "column", Type: parser.DTNumber}, {Name: "value", Type: parser.DTBool}})
+	newFuncCall("lists.sort", []Param{{Name: "list", Type: parser.DTStringList}}, []Param{{Name: "list", Type: parser.DTNumberList}})
+	newFuncCall("lists.reverse", []Param{{Name: "list", Type: parser.DTStringList}}, []Param{{Name: "list", Type: parser.DTNumberList}}, []Param{{Name: "list", Type: parser.DTBoolList}})
+	newFuncCall("lists.copy", []Param{{Name: "dst", Type: parser.DTStringList}, {Name: "src", Type: parser.DTStringList}}, []Param{{Name: "dst", Type: parser.DTNumberList}, {Name: "src", Type: parser.DTNumberList}}, []Param{{Name: "dst", Type: parser.DTBoolList}, {Name: "src", Type: parser.DTBoolList}})
+	newFuncCall("lists.slice", []Param{{Name: "dst", Type: parser.DTStringList}, {Name: "src", Type: parser.DTStringList}, {Name: "from", Type: parser.DTNumber}, {Name: "to", Type: parser.DTNumber}}, []Param{{Name: "dst", Type: parser.DTNumberList}, {Name: "src", Type: parser.DTNumberList}, {Name: "from", Type: parser.DTNumber}, {Name: "to", Type: parser.DTNumber}}, []Param{{Name: "dst", Type: parser.DTBoolList}, {Name: "src", Type: parser.DTBoolList}, {Name: "from", Type: parser.DTNumber}, {Name: "to", Type: parser.DTNumber}})
+	newFuncCall("lists.fill", []Param{{Name: "list", Type: parser.DTStringList}, {Name: "value", Type: parser.DTString}, {Name: "count", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTNumberList}, {Name: "value", Type: parser.DTNumber}, {Name: "count", Type: parser.DTNumber}}, []Param{{Name: "list", Type: parser.DTBoolList}, {Name: "value", Type: parser.DTBool}, {Name: "count", Type: parser.DTNumber}})
+	newFuncCall("strings.split", []Param{{Name: "str", Type: parser.DTString}, {Name: "separator", Type: parser.DTString}, {Name: "parts", Type: parser.DTStringList}})
 
 	newFuncCall("draw.begin")
 	newFuncCall("draw.finish")
@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"embed"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/juho05/embe/parser"
+)
+
+// Many functions of the strings and lists namespaces have no equivalent block. They are implemented in embe in the files
+// of the helpers directory and only added to the program when they are called. The functions of helpers/<namespace>.mb
+// are declared as <namespace>.<name>. A function which is implemented once for every list type is declared as
+// <namespace>.<name><type> and selected by the type of the first list argument.
+
+//go:embed helpers/*.mb
+var helperFiles embed.FS
+
+var helpersPath = filepath.Join(parser.LibraryPath, "helpers")
+
+// listHelpers contains the functions of the lists namespace which are implemented in helpers/lists.mb.
+var listHelpers = []string{
+	"lists.sort",
+	"lists.reverse",
+	"lists.sum",
+	"lists.min",
+	"lists.max",
+	"lists.average",
+	"lists.copy",
+	"lists.slice",
+	"lists.fill",
+}
+
+// helperFunctions contains the builtin functions which are implemented by helper functions.
+var helperFunctions = append(append([]string{}, stringHelpers...), listHelpers...)
+
+// integerPow is the helper function in helpers/math.mb which is called by the lowering of '**' for integer exponents.
+// It cannot be called directly.
+const integerPow = "math.integerPow"
+
+// IsHelper reports whether f was declared by the compiler to implement a builtin function.
+func IsHelper(f *Function) bool {
+	return filepath.Dir(f.Name.Pos.Path) == helpersPath
+}
+
+// isHelperCall reports whether expr needs to call a helper function because it cannot be folded.
+func (a *analyzer) isHelperCall(expr *parser.ExprFuncCall) bool {
+	return slices.Contains(helperFunctions, expr.Name.Lexeme) && !a.isConstantExpr(expr)
+}
+
+// requireHelper declares the helper function which implements name for args if it is not declared yet and returns its name.
+// The body is analyzed together with the specializations.
+func (a *analyzer) requireHelper(name string, args []parser.Expr) string {
+	if !slices.Contains(helperFunctions, name) && name != integerPow {
+		return name
+	}
+	if a.helpers == nil {
+		a.parseHelpers()
+	}
+
+	overloads := a.helpers[name]
+	decl := overloads[0]
+	if len(overloads) > 1 {
+		for i, p := range decl.Params {
+			if !isReferenceType(p.Type.DataType) || i >= len(args) {
+				continue
+			}
+			if ident, ok := args[i].(*parser.ExprIdentifier); ok {
+				arg := *ident
+				if arg.Accept(a) == nil {
+					for _, o := range overloads {
+						if o.Params[i].Type.DataType == arg.Type() {
+							decl = o
+						}
+					}
+				}
+			}
+			break
+		}
+	}
+
+	if _, ok := a.functions[decl.Name.Lexeme]; ok {
+		return decl.Name.Lexeme
+	}
+	if hasReferenceParams(decl.Params) {
+		a.declareTemplate(decl)
+		return decl.Name.Lexeme
+	}
+	a.specializations = append(a.specializations, &specialization{
+		decl:     decl,
+		fn:       a.declareFunction(decl),
+		bindings: make(map[string]string),
+	})
+	return decl.Name.Lexeme
+}
+
+func (a *analyzer) parseHelpers() {
+	a.helpers = make(map[string][]*parser.StmtFuncDecl)
+	entries, _ := helperFiles.ReadDir("helpers")
+	for _, entry := range entries {
+		source, _ := helperFiles.ReadFile("helpers/" + entry.Name())
+		tokens, _, errs := parser.Scan(strings.NewReader(string(source)), filepath.Join(helpersPath, entry.Name()))
+		statements, parseErrs := parser.Parse(tokens)
+		if len(errs) > 0 || len(parseErrs) > 0 {
+			panic(fmt.Sprintf("invalid helper file %s: %v", entry.Name(), append(errs, parseErrs...)))
+		}
+		namespace := strings.TrimSuffix(entry.Name(), ".mb")
+		for _, s := range statements {
+			decl := s.(*parser.StmtFuncDecl)
+			name := namespace + "." + decl.Name.Lexeme
+			a.helpers[name] = append(a.helpers[name], decl)
+		}
+	}
+
+	for name, overloads := range a.helpers {
+		for _, decl := range overloads {
+			decl.Name.Lexeme = name
+			if len(overloads) > 1 {
+				for _, p := range decl.Params {
+					if isReferenceType(p.Type.DataType) {
+						decl.Name.Lexeme = fmt.Sprintf("%s<%s>", name, p.Type.DataType)
+						break
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/juho05/embe/parser"
+)
+
+// Scratch procedures cannot report values. Calls to custom functions inside of expressions are therefore
+// moved into separate statements in front of the statement containing the expression. The call itself is
+// replaced with a read of the hidden return variable of the function.
+
+type pendingRead struct {
+	ident     *parser.ExprIdentifier
+	fn        *Function
+	converted bool
+}
+
+func (a *analyzer) beginStatement() {
+	a.hoisted = make([]parser.Stmt, 0)
+	a.pendingReads = make([]*pendingRead, 0)
+	a.tempCounts = make(map[string]int)
+	a.replaceStatement = false
+}
+
+func (a *analyzer) hoistCalls(expr parser.Expr) parser.Expr {
+	switch e := expr.(type) {
+	case *parser.ExprFuncCall:
+		start := len(a.pendingReads)
+		for i, p := range e.Parameters {
+			e.Parameters[i] = a.hoistCalls(p)
+		}
+
+		fn, ok := a.functions[e.Name.Lexeme]
+		if ok && fn.template != nil {
+			// the call could not be specialized, which has already been reported
+			return e
+		}
+		if !ok || a.isConstantExpr(e) {
+			if _, ok := mathLowerings[e.Name.Lexeme]; ok && !a.isConstantExpr(e) {
+				return a.hoistCalls(a.lowerMath(e.Name, e.Parameters, start))
+			}
+			return e
+		}
+
+		// The parameters of this call consume all reads which were added while hoisting them.
+		// Every other unconsumed read would be overwritten by this call and needs to be copied first.
+		a.pendingReads = a.pendingReads[:start]
+		if fn == a.currentFunction && len(a.pendingReads) > 0 {
+			// the copies are hidden global variables, which are reused by the recursive call
+			a.newWarningExpr("This recursive call overwrites the results of the calls before it in the same expression.", e)
+		}
+		for _, r := range a.pendingReads {
+			if !r.converted {
+				a.convertToTemp(r)
+			}
+		}
+
+		a.hoisted = append(a.hoisted, &parser.StmtCall{
+			Name:       e.Name,
+			Parameters: e.Parameters,
+		})
+
+		name := a.hiddenToken(fn.ReturnVariable.Name.Lexeme, e.Name)
+		name.EndPos = e.CloseParen.Pos
+		read := &parser.ExprIdentifier{
+			Name:       name,
+			ReturnType: fn.ReturnType,
+		}
+		a.pendingReads = append(a.pendingReads, &pendingRead{
+			ident: read,
+			fn:    fn,
+		})
+		return read
+	case *parser.ExprTernary:
+		return a.hoistTernary(e)
+	case *parser.ExprTypeCast:
+		e.Value = a.hoistCalls(e.Value)
+	case *parser.ExprUnary:
+		e.Right = a.hoistCalls(e.Right)
+	case *parser.ExprBinary:
+		start := len(a.pendingReads)
+		e.Left = a.hoistCalls(e.Left)
+		e.Right = a.hoistCalls(e.Right)
+		if e.Operator.Type == parser.TkPower && !a.isConstantExpr(e) {
+			return a.hoistCalls(a.lowerMath(e.Operator, []parser.Expr{e.Left, e.Right}, start))
+		}
+	case *parser.ExprGrouping:
+		e.Expr = a.hoistCalls(e.Expr)
+	case *parser.ExprListInitializer:
+		for i, v := range e.Values {
+			e.Values[i] = a.hoistCalls(v)
+		}
+	case *parser.ExprInterpolation:
+		for i, p := range e.Parts {
+			e.Parts[i] = a.hoistCalls(p)
+		}
+	}
+	return expr
+}
+
+// hoistTernary replaces a conditional expression with a read of a hidden variable which is set by an if statement
+// in front of the current statement. Only the selected value is evaluated.
+func (a *analyzer) hoistTernary(e *parser.ExprTernary) parser.Expr {
+	start := len(a.pendingReads)
+	e.Condition = a.hoistCalls(e.Condition)
+
+	name := a.hiddenToken(a.uniqueHiddenName("value"), e.QuestionMark)
+	a.hiddenVariable(name.Lexeme, e.ReturnType)
+
+	hoisted, pendingReads := a.hoisted, a.pendingReads
+	branch := func(value parser.Expr) []parser.Stmt {
+		a.hoisted = make([]parser.Stmt, 0)
+		a.pendingReads = make([]*pendingRead, 0)
+		value = a.hoistCalls(value)
+		return append(a.hoisted, newAssignStmt(name, value))
+	}
+	body := branch(e.TrueValue)
+	elseBody := branch(e.FalseValue)
+	a.hoisted, a.pendingReads = hoisted, pendingReads
+
+	// The if statement consumes all reads of the condition.
+	// Every other unconsumed read would be overwritten by calls in the branches and needs to be copied first.
+	a.pendingReads = a.pendingReads[:start]
+	if len(body) > 1 || len(elseBody) > 1 {
+		for _, r := range a.pendingReads {
+			if !r.converted {
+				a.convertToTemp(r)
+			}
+		}
+	}
+
+	a.hoisted = append(a.hoisted, &parser.StmtIf{
+		Keyword:   e.QuestionMark,
+		Condition: e.Condition,
+		Body:      body,
+		ElseBody:  elseBody,
+	})
+
+	_, end := e.FalseValue.Position()
+	name.EndPos = end
+	return &parser.ExprIdentifier{
+		Name:       name,
+		ReturnType: e.ReturnType,
+	}
+}
+
+// needsHoisting reports whether expr contains calls to custom functions, conditional expressions
+// or math operations which need to be lowered.
+func (a *analyzer) needsHoisting(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.ExprFuncCall:
+		if _, ok := a.functions[e.Name.Lexeme]; ok && !a.isConstantExpr(e) {
+			return true
+		}
+		if _, ok := mathLowerings[e.Name.Lexeme]; ok && !a.isConstantExpr(e) {
+			return true
+		}
+		for _, p := range e.Parameters {
+			if a.needsHoisting(p) {
+				return true
+			}
+		}
+	case *parser.ExprTernary:
+		return true
+	case *parser.ExprTypeCast:
+		return a.needsHoisting(e.Value)
+	case *parser.ExprUnary:
+		return a.needsHoisting(e.Right)
+	case *parser.ExprBinary:
+		if e.Operator.Type == parser.TkPower && !a.isConstantExpr(e) {
+			return true
+		}
+		return a.needsHoisting(e.Left) || a.needsHoisting(e.Right)
+	case *parser.ExprGrouping:
+		return a.needsHoisting(e.Expr)
+	case *parser.ExprInterpolation:
+		for _, p := range e.Parts {
+			if a.needsHoisting(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *analyzer) isPendingRead(ident *parser.ExprIdentifier) bool {
+	for _, r := range a.pendingReads {
+		if r.ident == ident {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *analyzer) convertToTemp(r *pendingRead) {
+	a.tempCounts[r.fn.Name.Lexeme]++
+	name := fmt.Sprintf("%s%d", r.fn.ReturnVariable.Name.Lexeme, a.tempCounts[r.fn.Name.Lexeme])
+	if _, ok := a.variables[name]; !ok {
+		a.hiddenVariable(name, r.fn.ReturnType)
+	}
+
+	a.hoisted = append(a.hoisted, &parser.StmtAssignment{
+		Variable: a.hiddenToken(name, r.ident.Name),
+		Operator: parser.Token{
+			Type: parser.TkAssign,
+		},
+		Value: &parser.ExprIdentifier{
+			Name:       r.ident.Name,
+			ReturnType: r.ident.ReturnType,
+		},
+	})
+	r.ident.Name.Lexeme = name
+	r.converted = true
+}
+
+// hiddenVariable declares a compiler generated variable which cannot be referenced in source code.
+func (a *analyzer) hiddenVariable(name string, dataType parser.DataType) *Variable {
+	variable := &Variable{
+		ID: uuid.NewString(),
+		Name: parser.Token{
+			Type:   parser.TkIdentifier,
+			Lexeme: name,
+		},
+		DataType: dataType,
+		declared: true,
+		used:     true,
+		changed:  true,
+	}
+	a.variables[name] = variable
+	return variable
+}
+
+func (a *analyzer) isConstant(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.ExprLiteral:
+		return true
+	case *parser.ExprIdentifier:
+		_, ok := a.constants[e.Name.Lexeme]
+		return ok
+	}
+	return false
+}
+
+// literalValue returns the value of expr if it is a literal or a constant with a literal value.
+func (a *analyzer) literalValue(expr parser.Expr) (any, bool) {
+	switch e := expr.(type) {
+	case *parser.ExprLiteral:
+		return e.Token.Literal, true
+	case *parser.ExprIdentifier:
+		c, ok := a.constants[e.Name.Lexeme]
+		if !ok {
+			return nil, false
+		}
+		if literal, ok := c.Value.(*parser.ExprLiteral); ok {
+			return literal.Token.Literal, true
+		}
+		// the values of enums are stored directly
+		if _, ok := c.Value.(parser.Expr); !ok {
+			return c.Value, true
+		}
+	}
+	return nil, false
+}
+
+func newAssignStmt(name parser.Token, value parser.Expr) *parser.StmtAssignment {
+	return &parser.StmtAssignment{
+		Variable: name,
+		Operator: parser.Token{
+			Type: parser.TkAssign,
+		},
+		Value: value,
+	}
+}
+
+func (a *analyzer) hiddenToken(name string, position parser.Token) parser.Token {
+	position.Type = parser.TkIdentifier
+	position.Lexeme = name
+	return position
+}
@@ -0,0 +1,298 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/juho05/embe/parser"
+)
+
+// Scratch only has one-dimensional lists. A 2D list is therefore stored row by row in a single list and its
+// dimensions are known at compile time. Accesses with lists.get2d and lists.set2d are replaced with accesses at the
+// computed index by the const calculator, which also folds reads from constant lists at constant indices.
+
+// tableFunctions contains the functions which only accept 2D lists.
+var tableFunctions = []string{"lists.get2d", "lists.set2d", "lists.rows", "lists.columns"}
+
+// sizePreservingFunctions contains the functions which change a list without changing its length.
+var sizePreservingFunctions = []string{"lists.replace", "lists.set2d", "lists.sort", "lists.reverse"}
+
+func isNestedList(init *parser.ExprListInitializer) bool {
+	for _, v := range init.Values {
+		if _, ok := v.(*parser.ExprListInitializer); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// listDimensions returns the number of rows and columns of the 2D list declared by stmt and replaces the rows of init with their values.
+func (a *analyzer) listDimensions(stmt *parser.StmtVarDecl, init *parser.ExprListInitializer) (rows, columns int, err error) {
+	if len(stmt.Dimensions) > 0 && stmt.Dimensions[0] != nil {
+		if rows, err = a.listSize(stmt.Dimensions[0]); err != nil {
+			return 0, 0, err
+		}
+		if columns, err = a.listSize(stmt.Dimensions[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if len(init.Values) == 0 {
+		if rows == 0 {
+			return 0, 0, a.newErrorTk("Cannot infer the dimensions of the list. Please provide them, e.g. number[3][4].", stmt.Name)
+		}
+		return rows, columns, nil
+	}
+
+	if rows == 0 {
+		rows = len(init.Values)
+		if first, ok := init.Values[0].(*parser.ExprListInitializer); ok {
+			columns = len(first.Values)
+		}
+	} else if len(init.Values) != rows {
+		return 0, 0, a.newErrorExpr(fmt.Sprintf("Expected %d rows.", rows), init)
+	}
+
+	values := make([]parser.Expr, 0, rows*columns)
+	for _, v := range init.Values {
+		row, ok := v.(*parser.ExprListInitializer)
+		if !ok {
+			return 0, 0, a.newErrorExpr("Expected a list of values for every row.", v)
+		}
+		if columns == 0 {
+			return 0, 0, a.newErrorExpr("The rows of 2D lists cannot be empty.", row)
+		}
+		if len(row.Values) != columns {
+			return 0, 0, a.newErrorExpr(fmt.Sprintf("Expected %d values in every row.", columns), row)
+		}
+		values = append(values, row.Values...)
+	}
+	init.Values = values
+	return rows, columns, nil
+}
+
+// listSize returns the value of a constant positive integer.
+func (a *analyzer) listSize(expr parser.Expr) (int, error) {
+	if ident, ok := expr.(*parser.ExprIdentifier); ok && a.isConstant(ident) {
+		if err := ident.Accept(a); err != nil {
+			return 0, err
+		}
+		expr = a.constants[ident.Name.Lexeme].Value.(parser.Expr)
+	}
+	if l, ok := expr.(*parser.ExprLiteral); ok {
+		if n, ok := l.Token.Literal.(float64); ok && n >= 1 && n == float64(int(n)) {
+			return int(n), nil
+		}
+	}
+	return 0, a.newErrorExpr("Expected a constant positive integer.", expr)
+}
+
+// fillTable creates a loop which fills the 2D list with zeros, empty strings or false.
+func fillTable(list *List) parser.Stmt {
+	b := exprBuilder{position: list.Name}
+	value := b.number(0)
+	switch list.DataType {
+	case parser.DTStringList:
+		token := b.token(parser.TkLiteral, "\"\"")
+		token.DataType = parser.DTString
+		token.Literal = ""
+		value = &parser.ExprLiteral{
+			Token:      token,
+			ReturnType: parser.DTString,
+		}
+	case parser.DTBoolList:
+		token := b.token(parser.TkLiteral, "false")
+		token.DataType = parser.DTBool
+		token.Literal = false
+		value = &parser.ExprLiteral{
+			Token:      token,
+			ReturnType: parser.DTBool,
+		}
+	}
+	return &parser.StmtLoop{
+		Keyword:   b.token(parser.TkFor, "for"),
+		Condition: b.number(float64(list.Rows * list.Columns)),
+		Body: []parser.Stmt{
+			&parser.StmtCall{
+				Name: b.token(parser.TkIdentifier, "lists.append"),
+				Parameters: []parser.Expr{
+					&parser.ExprIdentifier{
+						Name:       list.Name,
+						ReturnType: list.DataType,
+					},
+					value,
+				},
+			},
+		},
+	}
+}
+
+// declareConstList declares a list which cannot be changed.
+func (a *analyzer) declareConstList(stmt *parser.StmtConstDecl, init *parser.ExprListInitializer) error {
+	if len(init.Values) == 0 {
+		return a.newErrorExpr("Constant lists cannot be empty.", init)
+	}
+	decl := &parser.StmtVarDecl{
+		Name:        stmt.Name,
+		AssignToken: stmt.AssignToken,
+		Value:       init,
+	}
+	if err := a.VisitVarDecl(decl); err != nil {
+		return err
+	}
+	list := a.lists[stmt.Name.Lexeme]
+	list.Constant = true
+	if list.DataType == parser.DTBoolList {
+		return a.newErrorStmt("Boolean constants are not supported.", stmt)
+	}
+	return nil
+}
+
+// listArg returns the list passed as the first argument of a function call or nil.
+func (a *analyzer) listArg(params []parser.Expr) *List {
+	if len(params) == 0 {
+		return nil
+	}
+	ident, ok := params[0].(*parser.ExprIdentifier)
+	if !ok {
+		return nil
+	}
+	return a.lists[a.resolveLocal(ident.Name).Lexeme]
+}
+
+// checkListChange reports an error if stmt changes a constant list or the size of a 2D list.
+// Every builtin function which changes a list takes it as the first list argument.
+func (a *analyzer) checkListChange(stmt *parser.StmtCall) error {
+	if _, ok := FuncCalls[stmt.Name.Lexeme]; !ok {
+		return nil
+	}
+	for i, p := range stmt.Parameters {
+		list := a.listArg(stmt.Parameters[i:])
+		if list == nil {
+			continue
+		}
+		if list.Constant {
+			return a.newErrorExpr("Cannot change a constant list. Consider using 'var' instead.", p)
+		}
+		if list.Columns > 0 && !slices.Contains(sizePreservingFunctions, stmt.Name.Lexeme) {
+			return a.newErrorExpr("Cannot change the size of a 2D list.", p)
+		}
+		return nil
+	}
+	return nil
+}
+
+// checkTableArg reports an error if a function which only accepts 2D lists is called with another list.
+func (a *analyzer) checkTableArg(name string, params []parser.Expr) error {
+	if !slices.Contains(tableFunctions, name) {
+		return nil
+	}
+	if list := a.listArg(params); list == nil || (list.Columns == 0 && !list.placeholder) {
+		return a.newErrorExpr("Expected a 2D list.", params[0])
+	}
+	return nil
+}
+
+// isConstantListCall reports whether expr returns the size of a constant or 2D list or a value of a constant list at constant indices.
+func (a *analyzer) isConstantListCall(expr *parser.ExprFuncCall) bool {
+	list := a.listArg(expr.Parameters)
+	if list == nil {
+		return false
+	}
+	switch expr.Name.Lexeme {
+	case "lists.rows", "lists.columns":
+		return list.Columns > 0
+	case "lists.length":
+		return list.Constant || list.Columns > 0
+	case "lists.get", "lists.get2d":
+		if !list.Constant {
+			return false
+		}
+		for _, p := range expr.Parameters[1:] {
+			if !a.isConstantExpr(p) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (c *constCalculator) listArg(params []parser.Expr) *List {
+	if len(params) == 0 {
+		return nil
+	}
+	ident, ok := params[0].(*parser.ExprIdentifier)
+	if !ok {
+		return nil
+	}
+	return c.definitions.Lists[ident.Name.Lexeme]
+}
+
+// foldListCall replaces lists.get2d with lists.get and calculates the result of expr if it is constant.
+func (c *constCalculator) foldListCall(expr *parser.ExprFuncCall) (parser.Expr, error) {
+	list := c.listArg(expr.Parameters)
+	if list == nil {
+		return expr, nil
+	}
+	switch expr.Name.Lexeme {
+	case "lists.rows":
+		return c.newLiteral(float64(list.Rows), expr), nil
+	case "lists.columns":
+		return c.newLiteral(float64(list.Columns), expr), nil
+	case "lists.length":
+		if list.Columns > 0 {
+			return c.newLiteral(float64(list.Rows*list.Columns), expr), nil
+		}
+		if list.values != nil {
+			return c.newLiteral(float64(len(list.values)), expr), nil
+		}
+	case "lists.get2d":
+		index, err := c.tableIndex(list, expr.Name, expr.Parameters[1], expr.Parameters[2])
+		if err != nil {
+			return nil, err
+		}
+		expr.Name.Lexeme = "lists.get"
+		expr.Parameters = []parser.Expr{expr.Parameters[0], index}
+		return c.foldListCall(expr)
+	case "lists.get":
+		if l, ok := expr.Parameters[1].(*parser.ExprLiteral); ok && list.values != nil {
+			index := int(l.Token.Literal.(float64))
+			if index < 1 {
+				return nil, c.newErrorExpr("Indices start at 1.", expr.Parameters[1])
+			}
+			if index > len(list.values) {
+				return nil, c.newErrorExpr(fmt.Sprintf("Index out of range. Index: %d, length: %d", index, len(list.values)), expr.Parameters[1])
+			}
+			return c.newLiteral(list.values[index-1], expr), nil
+		}
+	}
+	return expr, nil
+}
+
+// tableIndex returns the index of the item at row and column in the 2D list.
+func (c *constCalculator) tableIndex(list *List, position parser.Token, row, column parser.Expr) (parser.Expr, error) {
+	if l, ok := row.(*parser.ExprLiteral); ok {
+		if n := int(l.Token.Literal.(float64)); n < 1 {
+			return nil, c.newErrorExpr("Indices start at 1.", row)
+		} else if n > list.Rows {
+			return nil, c.newErrorExpr(fmt.Sprintf("Row out of range. Row: %d, rows: %d", n, list.Rows), row)
+		}
+	}
+	if l, ok := column.(*parser.ExprLiteral); ok {
+		if n := int(l.Token.Literal.(float64)); n < 1 {
+			return nil, c.newErrorExpr("Indices start at 1.", column)
+		} else if n > list.Columns {
+			return nil, c.newErrorExpr(fmt.Sprintf("Column out of range. Column: %d, columns: %d", n, list.Columns), column)
+		}
+	}
+
+	// (row - 1) * columns + column
+	b := exprBuilder{position: position}
+	index := b.binary(parser.TkPlus, b.binary(parser.TkMultiply, b.binary(parser.TkMinus, row, b.number(1)), b.number(float64(list.Columns))), column)
+	if err := index.Accept(c); err != nil {
+		return nil, err
+	}
+	return c.newExpr, nil
+}
@@ -0,0 +1,332 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/juho05/embe/parser"
+)
+
+// Scratch has no way to leave a loop early. Every loop containing 'break' or 'continue' gets a hidden flag
+// variable which is set instead. All statements following a possible jump are only executed if the flag is unset
+// and the loop condition is extended to stop the loop once the flag signals a break.
+
+const (
+	loopNormal float64 = iota
+	loopBreak
+	loopContinue
+)
+
+type loop struct {
+	flag        *Variable
+	hasBreak    bool
+	hasContinue bool
+}
+
+func (l *loop) flagEquals(value float64) parser.Expr {
+	return &parser.ExprBinary{
+		Operator: parser.Token{
+			Type: parser.TkEqual,
+		},
+		Left: &parser.ExprIdentifier{
+			Name:       l.flag.Name,
+			ReturnType: parser.DTNumber,
+		},
+		Right:      newNumberLiteral(value),
+		ReturnType: parser.DTBool,
+	}
+}
+
+func (l *loop) setFlag(value float64, position parser.Token) parser.Stmt {
+	name := position
+	name.Type = parser.TkIdentifier
+	name.Lexeme = l.flag.Name.Lexeme
+	return &parser.StmtAssignment{
+		Variable: name,
+		Operator: parser.Token{
+			Type: parser.TkAssign,
+		},
+		Value: newNumberLiteral(value),
+	}
+}
+
+func (a *analyzer) VisitLoopControl(stmt *parser.StmtLoopControl) error {
+	if a.unreachable {
+		a.newWarningStmt("Unreachable code.", stmt)
+	}
+	a.unreachable = true
+
+	if len(a.loops) == 0 {
+		return a.newErrorStmt(fmt.Sprintf("'%s' is only allowed inside of loops.", stmt.Keyword.Lexeme), stmt)
+	}
+
+	l := a.loops[len(a.loops)-1]
+	if l.flag == nil {
+		l.flag = a.hiddenVariable(a.uniqueHiddenName("loop"), parser.DTNumber)
+	}
+
+	value := loopBreak
+	if stmt.Keyword.Type == parser.TkContinue {
+		value = loopContinue
+		l.hasContinue = true
+	} else {
+		l.hasBreak = true
+	}
+	a.hoisted = append(a.hoisted, l.setFlag(value, stmt.Keyword))
+	return nil
+}
+
+func (a *analyzer) lowerLoopControl(stmt *parser.StmtLoop, l *loop) {
+	stmt.Body = guardLoopControl(stmt.Body, l)
+
+	if l.hasContinue {
+		stmt.Body = append([]parser.Stmt{l.setFlag(loopNormal, stmt.Keyword)}, stmt.Body...)
+	}
+
+	if !l.hasBreak {
+		return
+	}
+	a.hoisted = append(a.hoisted, l.setFlag(loopNormal, stmt.Keyword))
+
+	switch {
+	case stmt.Condition == nil:
+		stmt.Condition = l.flagEquals(loopBreak)
+	case stmt.Keyword.Type == parser.TkWhile:
+		stmt.Condition = &parser.ExprBinary{
+			Operator: parser.Token{
+				Type: parser.TkOr,
+			},
+			Left:       stmt.Condition,
+			Right:      l.flagEquals(loopBreak),
+			ReturnType: parser.DTBool,
+		}
+	case stmt.Keyword.Type == parser.TkFor:
+		// 'control_repeat' cannot be stopped, so the loop is converted to a 'control_repeat_until' with a counter.
+		counter := a.hiddenVariable(a.uniqueHiddenName("count"), parser.DTNumber)
+		counterName := stmt.Keyword
+		counterName.Type = parser.TkIdentifier
+		counterName.Lexeme = counter.Name.Lexeme
+
+		a.hoisted = append(a.hoisted, &parser.StmtAssignment{
+			Variable: counterName,
+			Operator: parser.Token{
+				Type: parser.TkAssign,
+			},
+			Value: &parser.ExprFuncCall{
+				Name: parser.Token{
+					Type:   parser.TkIdentifier,
+					Lexeme: "math.round",
+				},
+				Parameters: []parser.Expr{stmt.Condition},
+				ReturnType: parser.DTNumber,
+			},
+		})
+		stmt.Body = append([]parser.Stmt{&parser.StmtAssignment{
+			Variable: counterName,
+			Operator: parser.Token{
+				Type: parser.TkPlusAssign,
+			},
+			Value: newNumberLiteral(-1),
+		}}, stmt.Body...)
+		stmt.Condition = &parser.ExprBinary{
+			Operator: parser.Token{
+				Type: parser.TkOr,
+			},
+			Left: &parser.ExprBinary{
+				Operator: parser.Token{
+					Type: parser.TkLess,
+				},
+				Left: &parser.ExprIdentifier{
+					Name:       counterName,
+					ReturnType: parser.DTNumber,
+				},
+				Right:      newNumberLiteral(1),
+				ReturnType: parser.DTBool,
+			},
+			Right:      l.flagEquals(loopBreak),
+			ReturnType: parser.DTBool,
+		}
+	}
+	stmt.Keyword.Type = parser.TkWhile
+}
+
+// lowerForIn converts a 'for x in ...' loop into a while loop. The returned statements need to be executed
+// at the start and at the end of every iteration.
+func (a *analyzer) lowerForIn(stmt *parser.StmtLoop) (prologue, epilogue []parser.Stmt, err error) {
+	if err := a.assertNotDeclaredLocal(stmt.Variable); err != nil {
+		return nil, nil, err
+	}
+
+	if r, ok := stmt.Condition.(*parser.ExprRange); ok {
+		for _, e := range []parser.Expr{r.Start, r.End} {
+			err := e.Accept(a)
+			if err != nil {
+				return nil, nil, err
+			}
+			if e.Type() != parser.DTNumber {
+				return nil, nil, a.newErrorExpr("Expected number.", e)
+			}
+		}
+		start := a.hoistCalls(r.Start)
+		end := a.hoistCalls(r.End)
+
+		// the end of the range is only evaluated once
+		if !a.isConstant(end) {
+			endVariable := a.hiddenVariable(a.uniqueHiddenName("end"), parser.DTNumber)
+			a.hoisted = append(a.hoisted, newAssignStmt(a.hiddenToken(endVariable.Name.Lexeme, stmt.Keyword), end))
+			end = &parser.ExprIdentifier{
+				Name:       a.hiddenToken(endVariable.Name.Lexeme, stmt.Keyword),
+				ReturnType: parser.DTNumber,
+			}
+		}
+
+		variable := a.declareLocalVariable(stmt.Variable, parser.DTNumber)
+		a.hoisted = append(a.hoisted, newAssignStmt(variable.Name, start))
+		stmt.Condition = &parser.ExprBinary{
+			Operator: parser.Token{
+				Type: parser.TkGreater,
+			},
+			Left: &parser.ExprIdentifier{
+				Name:       variable.Name,
+				ReturnType: parser.DTNumber,
+			},
+			Right:      end,
+			ReturnType: parser.DTBool,
+		}
+		epilogue = []parser.Stmt{&parser.StmtAssignment{
+			Variable: variable.Name,
+			Operator: parser.Token{
+				Type: parser.TkPlusAssign,
+			},
+			Value: newNumberLiteral(1),
+		}}
+	} else {
+		err := stmt.Condition.Accept(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !strings.HasSuffix(string(stmt.Condition.Type()), "[]") {
+			return nil, nil, a.newErrorExpr("Expected range or list.", stmt.Condition)
+		}
+		list := stmt.Condition
+
+		index := a.hiddenVariable(a.uniqueHiddenName("index"), parser.DTNumber)
+		indexName := a.hiddenToken(index.Name.Lexeme, stmt.Keyword)
+		a.hoisted = append(a.hoisted, newAssignStmt(indexName, newNumberLiteral(1)))
+
+		dataType := parser.DataType(strings.TrimSuffix(string(list.Type()), "[]"))
+		variable := a.declareLocalVariable(stmt.Variable, dataType)
+		stmt.Condition = &parser.ExprBinary{
+			Operator: parser.Token{
+				Type: parser.TkGreater,
+			},
+			Left: &parser.ExprIdentifier{
+				Name:       indexName,
+				ReturnType: parser.DTNumber,
+			},
+			Right: &parser.ExprFuncCall{
+				Name: parser.Token{
+					Type:   parser.TkIdentifier,
+					Lexeme: "lists.length",
+				},
+				Parameters: []parser.Expr{list},
+				ReturnType: parser.DTNumber,
+			},
+			ReturnType: parser.DTBool,
+		}
+		prologue = []parser.Stmt{newAssignStmt(variable.Name, &parser.ExprFuncCall{
+			Name: parser.Token{
+				Type:   parser.TkIdentifier,
+				Lexeme: "lists.get",
+			},
+			Parameters: []parser.Expr{
+				list,
+				&parser.ExprIdentifier{
+					Name:       indexName,
+					ReturnType: parser.DTNumber,
+				},
+			},
+			ReturnType: dataType,
+		})}
+		epilogue = []parser.Stmt{&parser.StmtAssignment{
+			Variable: indexName,
+			Operator: parser.Token{
+				Type: parser.TkPlusAssign,
+			},
+			Value: newNumberLiteral(1),
+		}}
+	}
+
+	stmt.Keyword.Type = parser.TkWhile
+	return prologue, epilogue, nil
+}
+
+func (a *analyzer) declareLocalVariable(name parser.Token, dataType parser.DataType) *Variable {
+	variable := &Variable{
+		ID:       uuid.NewString(),
+		Name:     a.declareLocal(name),
+		DataType: dataType,
+		declared: true,
+		local:    true,
+		source:   name,
+	}
+	a.variables[variable.Name.Lexeme] = variable
+	return variable
+}
+
+// guardLoopControl wraps all statements which follow a possible jump in an if statement checking the flag of l.
+func guardLoopControl(body []parser.Stmt, l *loop) []parser.Stmt {
+	for i, s := range body {
+		if !jumps(s, l) {
+			continue
+		}
+		if _, ok := s.(*parser.StmtAssignment); ok {
+			return body[:i+1]
+		}
+		if stmt, ok := s.(*parser.StmtIf); ok {
+			stmt.Body = guardLoopControl(stmt.Body, l)
+			stmt.ElseBody = guardLoopControl(stmt.ElseBody, l)
+		}
+		if i == len(body)-1 {
+			return body
+		}
+		return append(body[:i+1:i+1], &parser.StmtIf{
+			Condition: l.flagEquals(loopNormal),
+			Body:      guardLoopControl(body[i+1:], l),
+		})
+	}
+	return body
+}
+
+func jumps(stmt parser.Stmt, l *loop) bool {
+	switch s := stmt.(type) {
+	case *parser.StmtAssignment:
+		return s.Variable.Lexeme == l.flag.Name.Lexeme
+	case *parser.StmtIf:
+		for _, b := range s.Body {
+			if jumps(b, l) {
+				return true
+			}
+		}
+		for _, b := range s.ElseBody {
+			if jumps(b, l) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newNumberLiteral(value float64) *parser.ExprLiteral {
+	return &parser.ExprLiteral{
+		Token: parser.Token{
+			Type:     parser.TkLiteral,
+			Lexeme:   fmt.Sprint(value),
+			Literal:  value,
+			DataType: parser.DTNumber,
+		},
+		ReturnType: parser.DTNumber,
+	}
+}
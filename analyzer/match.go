@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/juho05/embe/parser"
+)
+
+// VisitMatch converts the match statement into a chain of if statements comparing a hidden copy of the subject.
+func (a *analyzer) VisitMatch(stmt *parser.StmtMatch) error {
+	if a.unreachable {
+		a.newWarningStmt("Unreachable code.", stmt)
+	}
+	a.checkEnumMatch(stmt)
+
+	err := stmt.Subject.Accept(a)
+	if err != nil {
+		return err
+	}
+	subjectType := stmt.Subject.Type()
+	if subjectType != parser.DTNumber && subjectType != parser.DTString {
+		return a.newErrorExpr("Expected number or string.", stmt.Subject)
+	}
+
+	values := make(map[any]bool)
+	for _, c := range stmt.Cases {
+		for _, v := range c.Values {
+			err := v.Accept(a)
+			if err != nil {
+				return err
+			}
+			if v.Type() != subjectType {
+				return a.newErrorExpr(fmt.Sprintf("Wrong data type. Expected %s.", subjectType), v)
+			}
+			if value, ok := a.literalValue(v); ok {
+				if values[value] {
+					a.newWarningExpr("Duplicate case value. Only the first case is executed.", v)
+				}
+				values[value] = true
+			}
+		}
+	}
+
+	subject := a.hoistCalls(stmt.Subject)
+	if !a.isConstant(subject) {
+		variable := a.hiddenVariable(a.uniqueHiddenName("match"), subjectType)
+		name := a.hiddenToken(variable.Name.Lexeme, stmt.Keyword)
+		a.hoisted = append(a.hoisted, newAssignStmt(name, subject))
+		subject = &parser.ExprIdentifier{
+			Name:       name,
+			ReturnType: subjectType,
+		}
+	}
+
+	var first *parser.StmtIf
+	var last *parser.StmtIf
+	for _, c := range stmt.Cases {
+		var condition parser.Expr
+		for _, v := range c.Values {
+			equals := &parser.ExprBinary{
+				Operator: parser.Token{
+					Type: parser.TkEqual,
+				},
+				Left:  subject,
+				Right: v,
+			}
+			if condition == nil {
+				condition = equals
+			} else {
+				condition = &parser.ExprBinary{
+					Operator: parser.Token{
+						Type: parser.TkOr,
+					},
+					Left:  condition,
+					Right: equals,
+				}
+			}
+		}
+
+		ifStmt := &parser.StmtIf{
+			Keyword:   c.Keyword,
+			Condition: condition,
+			Body:      c.Body,
+		}
+		if first == nil {
+			first = ifStmt
+		} else {
+			last.ElseBody = []parser.Stmt{ifStmt}
+		}
+		last = ifStmt
+	}
+	last.ElseBody = stmt.ElseBody
+
+	// unreachable match statements have already been reported
+	unreachable := a.unreachable
+	a.unreachable = false
+	err = first.Accept(a)
+	a.unreachable = unreachable
+	if err != nil {
+		return err
+	}
+	a.hoisted = append(a.hoisted, first)
+	return nil
+}
@@ -0,0 +1,236 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/juho05/embe/parser"
+)
+
+// Scratch has no blocks for exponentiation, min, max etc. Calls to these functions and the '**' operator are
+// therefore lowered to arithmetic, operator_mathop blocks and conditional expressions while hoisting calls.
+// Calls with constant arguments are left as they are and folded by the const calculator.
+
+// maxUnrolledExponent is the largest integer exponent which is lowered to a chain of multiplications.
+const maxUnrolledExponent = 16
+
+type mathLowering func(b exprBuilder, args []func() parser.Expr) parser.Expr
+
+var mathLowerings = map[string]mathLowering{
+	"math.pow":   lowerPow,
+	"math.min":   lowerMin,
+	"math.max":   lowerMax,
+	"math.clamp": lowerClamp,
+	"math.map":   lowerMap,
+	"math.sign":  lowerSign,
+	"math.atan2": lowerAtan2,
+}
+
+// lowerMath replaces a math operation with an equivalent expression. Every parameter which is not a literal or a variable
+// is evaluated only once and stored in a hidden variable.
+func (a *analyzer) lowerMath(name parser.Token, params []parser.Expr, start int) parser.Expr {
+	args := make([]func() parser.Expr, len(params))
+	for i, p := range params {
+		if ident, ok := p.(*parser.ExprIdentifier); ok && a.isConstant(ident) {
+			if literal, ok := a.constants[ident.Name.Lexeme].Value.(*parser.ExprLiteral); ok {
+				p = literal
+			}
+		}
+		args[i] = a.reusableValue(p, "arg")
+	}
+	// The hidden variables consume all reads which were added while hoisting the parameters.
+	a.pendingReads = a.pendingReads[:start]
+
+	lowering := lowerPow
+	if name.Type != parser.TkPower {
+		lowering = mathLowerings[name.Lexeme]
+	}
+	if (name.Type == parser.TkPower || name.Lexeme == "math.pow") && powUsesHelper(args[1]()) {
+		a.functions[a.requireHelper(integerPow, nil)].used = true
+	}
+	return lowering(exprBuilder{position: name}, args)
+}
+
+// isConstantExpr reports whether expr can be folded by the const calculator.
+func (a *analyzer) isConstantExpr(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.ExprLiteral, *parser.ExprIdentifier:
+		return a.isConstant(e)
+	case *parser.ExprGrouping:
+		return a.isConstantExpr(e.Expr)
+	case *parser.ExprBinary:
+		switch e.Operator.Type {
+		case parser.TkPlus, parser.TkMinus, parser.TkMultiply, parser.TkDivide, parser.TkModulus, parser.TkPower:
+			return a.isConstantExpr(e.Left) && a.isConstantExpr(e.Right)
+		}
+	case *parser.ExprFuncCall:
+		if a.isConstantListCall(e) {
+			return true
+		}
+		if _, ok := mathLowerings[e.Name.Lexeme]; !ok && !slices.Contains(stringHelpers, e.Name.Lexeme) {
+			return false
+		}
+		for _, p := range e.Parameters {
+			if !a.isConstantExpr(p) {
+				return false
+			}
+		}
+		return a.canFoldString(e)
+	}
+	return false
+}
+
+// base ** exponent
+func lowerPow(b exprBuilder, args []func() parser.Expr) parser.Expr {
+	base, exponent := args[0], args[1]
+
+	// e ^ (exponent * ln(|base|)) is only used for fractional exponents, because it is not exact
+	power := func() parser.Expr {
+		magnitude := b.call("math.ePowerOf", b.binary(parser.TkMultiply, exponent(), b.call("math.ln", b.call("math.abs", base()))))
+		return b.ternary(b.binary(parser.TkEqual, base(), b.number(0)), b.number(0), magnitude)
+	}
+
+	l, ok := exponent().(*parser.ExprLiteral)
+	if !ok {
+		integer := b.binary(parser.TkEqual, b.call("math.round", exponent()), exponent())
+		return b.ternary(integer, b.call(integerPow, base(), exponent()), power())
+	}
+
+	n := l.Token.Literal.(float64)
+	if n != math.Trunc(n) {
+		return power()
+	}
+	if math.Abs(n) > maxUnrolledExponent {
+		return b.call(integerPow, base(), exponent())
+	}
+
+	var product parser.Expr = b.number(1)
+	if n != 0 {
+		product = base()
+		for i := 1; i < int(math.Abs(n)); i++ {
+			product = b.binary(parser.TkMultiply, product, base())
+		}
+	}
+	if n < 0 {
+		product = b.binary(parser.TkDivide, b.number(1), product)
+	}
+	return product
+}
+
+// powUsesHelper reports whether lowerPow calls the integerPow helper function for exponent.
+func powUsesHelper(exponent parser.Expr) bool {
+	l, ok := exponent.(*parser.ExprLiteral)
+	if !ok {
+		return true
+	}
+	n := l.Token.Literal.(float64)
+	return n == math.Trunc(n) && math.Abs(n) > maxUnrolledExponent
+}
+
+// a < b ? a : b
+func lowerMin(b exprBuilder, args []func() parser.Expr) parser.Expr {
+	return b.ternary(b.binary(parser.TkLess, args[0](), args[1]()), args[0](), args[1]())
+}
+
+// a > b ? a : b
+func lowerMax(b exprBuilder, args []func() parser.Expr) parser.Expr {
+	return b.ternary(b.binary(parser.TkGreater, args[0](), args[1]()), args[0](), args[1]())
+}
+
+// n < min ? min : (n > max ? max : n)
+func lowerClamp(b exprBuilder, args []func() parser.Expr) parser.Expr {
+	n, min, max := args[0], args[1], args[2]
+	return b.ternary(b.binary(parser.TkLess, n(), min()), min(),
+		b.ternary(b.binary(parser.TkGreater, n(), max()), max(), n()),
+	)
+}
+
+// outMin + (n - inMin) * (outMax - outMin) / (inMax - inMin)
+func lowerMap(b exprBuilder, args []func() parser.Expr) parser.Expr {
+	n, inMin, inMax, outMin, outMax := args[0], args[1], args[2], args[3], args[4]
+	scaled := b.binary(parser.TkMultiply, b.binary(parser.TkMinus, n(), inMin()), b.binary(parser.TkMinus, outMax(), outMin()))
+	return b.binary(parser.TkPlus, outMin(), b.binary(parser.TkDivide, scaled, b.binary(parser.TkMinus, inMax(), inMin())))
+}
+
+// n > 0 ? 1 : (n < 0 ? -1 : 0)
+func lowerSign(b exprBuilder, args []func() parser.Expr) parser.Expr {
+	n := args[0]
+	return b.ternary(b.binary(parser.TkGreater, n(), b.number(0)), b.number(1),
+		b.ternary(b.binary(parser.TkLess, n(), b.number(0)), b.number(-1), b.number(0)),
+	)
+}
+
+// atan(y / x) corrected by the quadrant of (x, y), in degrees like math.atan
+func lowerAtan2(b exprBuilder, args []func() parser.Expr) parser.Expr {
+	y, x := args[0], args[1]
+	atan := func() parser.Expr {
+		return b.call("math.atan", b.binary(parser.TkDivide, y(), x()))
+	}
+	left := b.binary(parser.TkPlus, atan(), b.ternary(b.binary(parser.TkLess, y(), b.number(0)), b.number(-180), b.number(180)))
+	vertical := b.ternary(b.binary(parser.TkGreater, y(), b.number(0)), b.number(90),
+		b.ternary(b.binary(parser.TkLess, y(), b.number(0)), b.number(-90), b.number(0)),
+	)
+	return b.ternary(b.binary(parser.TkGreater, x(), b.number(0)), atan(),
+		b.ternary(b.binary(parser.TkLess, x(), b.number(0)), left, vertical),
+	)
+}
+
+// exprBuilder creates analyzed expressions located at position.
+type exprBuilder struct {
+	position parser.Token
+}
+
+func (b exprBuilder) token(tokenType parser.TokenType, lexeme string) parser.Token {
+	token := b.position
+	token.Type = tokenType
+	token.Lexeme = lexeme
+	token.DataType = ""
+	token.Literal = nil
+	return token
+}
+
+func (b exprBuilder) number(value float64) parser.Expr {
+	token := b.token(parser.TkLiteral, fmt.Sprint(value))
+	token.DataType = parser.DTNumber
+	token.Literal = value
+	return &parser.ExprLiteral{
+		Token:      token,
+		ReturnType: parser.DTNumber,
+	}
+}
+
+func (b exprBuilder) binary(operator parser.TokenType, left, right parser.Expr) parser.Expr {
+	returnType := parser.DTNumber
+	switch operator {
+	case parser.TkLess, parser.TkGreater, parser.TkEqual, parser.TkAnd, parser.TkOr:
+		returnType = parser.DTBool
+	}
+	return &parser.ExprBinary{
+		Operator:   b.token(operator, b.position.Lexeme),
+		Left:       left,
+		Right:      right,
+		ReturnType: returnType,
+	}
+}
+
+func (b exprBuilder) ternary(condition, trueValue, falseValue parser.Expr) parser.Expr {
+	return &parser.ExprTernary{
+		Condition:    condition,
+		QuestionMark: b.token(parser.TkQuestionMark, b.position.Lexeme),
+		TrueValue:    trueValue,
+		Colon:        b.token(parser.TkColon, b.position.Lexeme),
+		FalseValue:   falseValue,
+		ReturnType:   trueValue.Type(),
+	}
+}
+
+func (b exprBuilder) call(name string, params ...parser.Expr) parser.Expr {
+	return &parser.ExprFuncCall{
+		Name:       b.token(parser.TkIdentifier, name),
+		Parameters: params,
+		ReturnType: parser.DTNumber,
+		CloseParen: b.token(parser.TkCloseParen, ")"),
+	}
+}
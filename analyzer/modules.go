@@ -0,0 +1,489 @@
+package analyzer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/juho05/embe/parser"
+)
+
+// Imported modules are resolved before the analysis. The declarations of a module are renamed to '<module>.<name>'
+// if they are exported and to '$<module>.<name>' otherwise, which also determines the names of the Scratch variables,
+// lists and procedures. Modules imported by other modules are prefixed with the name of the importing module.
+// Every import statement is replaced by the renamed statements of the module. A file which is imported multiple times
+// is only included once and all of its imports refer to the same variables and functions.
+
+type module struct {
+	name string
+	file string
+	// declarations contains the top level declarations of the module by their original names.
+	declarations map[string]parser.Stmt
+	exports      map[string]bool
+}
+
+// mangle returns the name of the declaration name of the module after the imports are resolved.
+func (m *module) mangle(name string) string {
+	if m.exports[name] {
+		return m.name + "." + name
+	}
+	return "$" + m.name + "." + name
+}
+
+// moduleRenamer renames all references to the declarations of the current module and of the modules imported by it.
+type moduleRenamer struct {
+	a *analyzer
+	// module is nil for the main file.
+	module       *module
+	declarations map[string]parser.Stmt
+	aliases      map[string]*module
+	scopes       []map[string]bool
+}
+
+// resolveModules replaces all import statements with the renamed statements of the imported modules.
+func (a *analyzer) resolveModules(statements []parser.Stmt) []parser.Stmt {
+	return a.resolveModule(statements, nil, make(map[string]*module))
+}
+
+func (a *analyzer) resolveModule(statements []parser.Stmt, mod *module, modules map[string]*module) []parser.Stmt {
+	r := &moduleRenamer{
+		a:            a,
+		module:       mod,
+		declarations: make(map[string]parser.Stmt),
+		aliases:      make(map[string]*module),
+	}
+	if mod != nil {
+		r.declarations = mod.declarations
+	} else {
+		r.declarations = declarations(statements)
+	}
+
+	result := make([]parser.Stmt, 0, len(statements))
+	for _, stmt := range statements {
+		imp, ok := stmt.(*parser.StmtImport)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+
+		if err := r.assertValidAlias(imp.Alias); err != nil {
+			a.errors = append(a.errors, err)
+			continue
+		}
+
+		if m, ok := modules[imp.File]; ok {
+			r.aliases[imp.Alias.Lexeme] = m
+			continue
+		}
+
+		name := imp.Alias.Lexeme
+		if mod != nil {
+			name = mod.name + "." + name
+		}
+		m := &module{
+			name:         name,
+			file:         imp.File,
+			declarations: declarations(imp.Body),
+			exports:      make(map[string]bool, len(imp.Exports)),
+		}
+		for _, e := range imp.Exports {
+			m.exports[e.Lexeme] = true
+			a.exported[m.mangle(e.Lexeme)] = true
+		}
+		a.assertUniqueDeclarations(imp.Body)
+		modules[imp.File] = m
+		r.aliases[imp.Alias.Lexeme] = m
+		result = append(result, a.resolveModule(imp.Body, m, modules)...)
+	}
+
+	for _, stmt := range statements {
+		if _, ok := stmt.(*parser.StmtImport); ok {
+			continue
+		}
+		if err := stmt.Accept(r); err != nil {
+			a.errors = append(a.errors, err)
+		}
+	}
+	return result
+}
+
+// declarations returns the top level declarations in statements by their names.
+func declarations(statements []parser.Stmt) map[string]parser.Stmt {
+	decls := make(map[string]parser.Stmt, len(statements))
+	for _, stmt := range statements {
+		if name, ok := declarationName(stmt); ok {
+			if _, ok := decls[name.Lexeme]; !ok {
+				decls[name.Lexeme] = stmt
+			}
+		}
+	}
+	return decls
+}
+
+func declarationName(stmt parser.Stmt) (parser.Token, bool) {
+	switch s := stmt.(type) {
+	case *parser.StmtVarDecl:
+		return s.Name, true
+	case *parser.StmtConstDecl:
+		return s.Name, true
+	case *parser.StmtFuncDecl:
+		return s.Name, true
+	case *parser.StmtEventDecl:
+		return s.Name, true
+	case *parser.StmtTypeDecl:
+		return s.Name, true
+	case *parser.StmtEnumDecl:
+		return s.Name, true
+	}
+	return parser.Token{}, false
+}
+
+// assertUniqueDeclarations reports declarations with the same name in a module.
+// Their renamed names can differ if only one of them is exported, so the analyzer would not notice them.
+func (a *analyzer) assertUniqueDeclarations(statements []parser.Stmt) {
+	declared := make(map[string]parser.Token, len(statements))
+	for _, stmt := range statements {
+		name, ok := declarationName(stmt)
+		if !ok {
+			continue
+		}
+		if other, ok := declared[name.Lexeme]; ok {
+			a.errors = append(a.errors, a.newErrorTk(fmt.Sprintf("'%s' is already declared in `%s` line %d.", name.Lexeme, filepath.Base(other.Pos.Path), other.Pos.Line+1), name))
+			continue
+		}
+		declared[name.Lexeme] = name
+	}
+}
+
+func (r *moduleRenamer) assertValidAlias(alias parser.Token) error {
+	if decl, ok := r.declarations[alias.Lexeme]; ok {
+		name, _ := declarationName(decl)
+		return r.a.newErrorTk(fmt.Sprintf("'%s' is already declared in `%s` line %d.", alias.Lexeme, filepath.Base(name.Pos.Path), name.Pos.Line+1), alias)
+	}
+	if _, ok := r.aliases[alias.Lexeme]; ok {
+		return r.a.newErrorTk(fmt.Sprintf("A module named '%s' is already imported.", alias.Lexeme), alias)
+	}
+	if isBuiltinNamespace(alias.Lexeme) {
+		return r.a.newErrorTk(fmt.Sprintf("'%s' is the name of builtin functions or variables.", alias.Lexeme), alias)
+	}
+	return nil
+}
+
+func isBuiltinNamespace(name string) bool {
+	prefix := name + "."
+	for n := range FuncCalls {
+		if strings.HasPrefix(n, prefix) {
+			return true
+		}
+	}
+	for n := range ExprFuncCalls {
+		if strings.HasPrefix(n, prefix) {
+			return true
+		}
+	}
+	for n := range Variables {
+		if strings.HasPrefix(n, prefix) {
+			return true
+		}
+	}
+	for n := range Assignments {
+		if strings.HasPrefix(n, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// declKind reports which kinds of declarations a name can refer to in its context.
+type declKind func(stmt parser.Stmt) bool
+
+func isCallable(stmt parser.Stmt) bool {
+	switch stmt.(type) {
+	case *parser.StmtFuncDecl, *parser.StmtEventDecl:
+		return true
+	}
+	return false
+}
+
+func isValue(stmt parser.Stmt) bool {
+	switch stmt.(type) {
+	case *parser.StmtVarDecl, *parser.StmtConstDecl, *parser.StmtEnumDecl:
+		return true
+	}
+	return false
+}
+
+func isType(stmt parser.Stmt) bool {
+	switch stmt.(type) {
+	case *parser.StmtTypeDecl, *parser.StmtEnumDecl:
+		return true
+	}
+	return false
+}
+
+func isEvent(stmt parser.Stmt) bool {
+	_, ok := stmt.(*parser.StmtEventDecl)
+	return ok
+}
+
+// rename renames name if it refers to a declaration of a matching kind in the current module or in an imported module.
+// Names of members of record variables and enums keep their suffix.
+func (r *moduleRenamer) rename(name *parser.Token, kind declKind) {
+	head, rest, hasRest := strings.Cut(name.Lexeme, ".")
+	if r.isLocal(head) {
+		return
+	}
+
+	if decl, ok := r.declarations[head]; ok {
+		if kind(decl) && r.module != nil {
+			name.Lexeme = r.module.mangle(head)
+			if hasRest {
+				name.Lexeme += "." + rest
+			}
+		}
+		return
+	}
+
+	m, ok := r.aliases[head]
+	if !ok || !hasRest {
+		return
+	}
+	member, suffix, hasSuffix := strings.Cut(rest, ".")
+	decl, ok := m.declarations[member]
+	if !ok || !kind(decl) {
+		return
+	}
+	if !m.exports[member] {
+		// the name is still renamed to avoid follow-up errors
+		r.a.errors = append(r.a.errors, r.a.newErrorTk(fmt.Sprintf("'%s' is not exported by module '%s'.", member, head), *name))
+	}
+	name.Lexeme = m.mangle(member)
+	if hasSuffix {
+		name.Lexeme += "." + suffix
+	}
+}
+
+func (r *moduleRenamer) isLocal(name string) bool {
+	for _, scope := range r.scopes {
+		if scope[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *moduleRenamer) beginScope(names ...parser.Token) {
+	scope := make(map[string]bool, len(names))
+	for _, n := range names {
+		scope[n.Lexeme] = true
+	}
+	r.scopes = append(r.scopes, scope)
+}
+
+func (r *moduleRenamer) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *moduleRenamer) body(stmts []parser.Stmt, names ...parser.Token) error {
+	r.beginScope(names...)
+	defer r.endScope()
+	for _, s := range stmts {
+		if err := s.Accept(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *moduleRenamer) expr(expr parser.Expr) error {
+	if expr == nil {
+		return nil
+	}
+	return expr.Accept(r)
+}
+
+func (r *moduleRenamer) exprs(exprs []parser.Expr) error {
+	for _, e := range exprs {
+		if err := r.expr(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *moduleRenamer) VisitVarDecl(stmt *parser.StmtVarDecl) error {
+	if err := r.expr(stmt.Value); err != nil {
+		return err
+	}
+	if stmt.Type.Type == parser.TkIdentifier {
+		r.rename(&stmt.Type, isType)
+		list := strings.HasSuffix(string(stmt.DataType), "[]")
+		stmt.DataType = parser.DataType(stmt.Type.Lexeme)
+		if list {
+			stmt.DataType += "[]"
+		}
+	}
+	if len(r.scopes) > 0 {
+		r.scopes[len(r.scopes)-1][stmt.Name.Lexeme] = true
+	} else {
+		r.rename(&stmt.Name, isValue)
+	}
+	return nil
+}
+
+func (r *moduleRenamer) VisitConstDecl(stmt *parser.StmtConstDecl) error {
+	r.rename(&stmt.Name, isValue)
+	return r.expr(stmt.Value)
+}
+
+func (r *moduleRenamer) VisitFuncDecl(stmt *parser.StmtFuncDecl) error {
+	r.rename(&stmt.Name, isCallable)
+	params := make([]parser.Token, len(stmt.Params))
+	for i, p := range stmt.Params {
+		params[i] = p.Name
+	}
+	return r.body(stmt.Body, params...)
+}
+
+func (r *moduleRenamer) VisitEventDecl(stmt *parser.StmtEventDecl) error {
+	r.rename(&stmt.Name, isEvent)
+	return nil
+}
+
+func (r *moduleRenamer) VisitTypeDecl(stmt *parser.StmtTypeDecl) error {
+	r.rename(&stmt.Name, isType)
+	return nil
+}
+
+func (r *moduleRenamer) VisitEnumDecl(stmt *parser.StmtEnumDecl) error {
+	r.rename(&stmt.Name, isType)
+	return nil
+}
+
+func (r *moduleRenamer) VisitEvent(stmt *parser.StmtEvent) error {
+	r.rename(&stmt.Name, isEvent)
+	if err := r.expr(stmt.Parameter); err != nil {
+		return err
+	}
+	return r.body(stmt.Body, stmt.Params...)
+}
+
+func (r *moduleRenamer) VisitCall(stmt *parser.StmtCall) error {
+	r.rename(&stmt.Name, isCallable)
+	return r.exprs(stmt.Parameters)
+}
+
+func (r *moduleRenamer) VisitAssignment(stmt *parser.StmtAssignment) error {
+	r.rename(&stmt.Variable, isValue)
+	return r.expr(stmt.Value)
+}
+
+func (r *moduleRenamer) VisitIf(stmt *parser.StmtIf) error {
+	if err := r.expr(stmt.Condition); err != nil {
+		return err
+	}
+	if err := r.body(stmt.Body); err != nil {
+		return err
+	}
+	return r.body(stmt.ElseBody)
+}
+
+func (r *moduleRenamer) VisitLoop(stmt *parser.StmtLoop) error {
+	if err := r.expr(stmt.Condition); err != nil {
+		return err
+	}
+	if stmt.Variable.Type == parser.TkIdentifier {
+		return r.body(stmt.Body, stmt.Variable)
+	}
+	return r.body(stmt.Body)
+}
+
+func (r *moduleRenamer) VisitReturn(stmt *parser.StmtReturn) error {
+	return r.expr(stmt.Value)
+}
+
+func (r *moduleRenamer) VisitLoopControl(stmt *parser.StmtLoopControl) error {
+	return nil
+}
+
+func (r *moduleRenamer) VisitMatch(stmt *parser.StmtMatch) error {
+	if err := r.expr(stmt.Subject); err != nil {
+		return err
+	}
+	for _, c := range stmt.Cases {
+		if err := r.exprs(c.Values); err != nil {
+			return err
+		}
+		if err := r.body(c.Body); err != nil {
+			return err
+		}
+	}
+	return r.body(stmt.ElseBody)
+}
+
+func (r *moduleRenamer) VisitImport(stmt *parser.StmtImport) error {
+	return r.a.newErrorTk("Modules can only be imported at the top level.", stmt.Keyword)
+}
+
+func (r *moduleRenamer) VisitIdentifier(expr *parser.ExprIdentifier) error {
+	r.rename(&expr.Name, isValue)
+	return nil
+}
+
+func (r *moduleRenamer) VisitExprFuncCall(expr *parser.ExprFuncCall) error {
+	r.rename(&expr.Name, isCallable)
+	return r.exprs(expr.Parameters)
+}
+
+func (r *moduleRenamer) VisitTypeCast(expr *parser.ExprTypeCast) error {
+	return r.expr(expr.Value)
+}
+
+func (r *moduleRenamer) VisitLiteral(expr *parser.ExprLiteral) error {
+	return nil
+}
+
+func (r *moduleRenamer) VisitListInitializer(expr *parser.ExprListInitializer) error {
+	return r.exprs(expr.Values)
+}
+
+func (r *moduleRenamer) VisitUnary(expr *parser.ExprUnary) error {
+	return r.expr(expr.Right)
+}
+
+func (r *moduleRenamer) VisitBinary(expr *parser.ExprBinary) error {
+	if err := r.expr(expr.Left); err != nil {
+		return err
+	}
+	return r.expr(expr.Right)
+}
+
+func (r *moduleRenamer) VisitGrouping(expr *parser.ExprGrouping) error {
+	return r.expr(expr.Expr)
+}
+
+func (r *moduleRenamer) VisitRange(expr *parser.ExprRange) error {
+	if err := r.expr(expr.Start); err != nil {
+		return err
+	}
+	return r.expr(expr.End)
+}
+
+func (r *moduleRenamer) VisitTernary(expr *parser.ExprTernary) error {
+	if err := r.expr(expr.Condition); err != nil {
+		return err
+	}
+	if err := r.expr(expr.TrueValue); err != nil {
+		return err
+	}
+	return r.expr(expr.FalseValue)
+}
+
+func (r *moduleRenamer) VisitInterpolation(expr *parser.ExprInterpolation) error {
+	return r.exprs(expr.Parts)
+}
+
+// VisitImport is never called because imports are resolved before the analysis.
+func (a *analyzer) VisitImport(stmt *parser.StmtImport) error {
+	panic("Should never be called.")
+}
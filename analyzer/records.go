@@ -0,0 +1,411 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/juho05/embe/parser"
+)
+
+// Scratch has no compound data types. Every field of a record variable is therefore stored in its own variable
+// named '<variable>.<field>' and every field of a list of records in its own list. Operations on whole records
+// are expanded into one operation per field.
+
+type Record struct {
+	Name   parser.Token
+	Fields []parser.FuncParam
+}
+
+type recordVariable struct {
+	Name   parser.Token
+	source parser.Token
+	record *Record
+	list   bool
+	// fields contains the names of the backing variables or lists in the order of the record fields.
+	fields []string
+}
+
+func (a *analyzer) VisitTypeDecl(stmt *parser.StmtTypeDecl) error {
+	if err := a.assertNotDeclared(stmt.Name); err != nil {
+		return err
+	}
+	if len(stmt.Fields) == 0 {
+		return a.newErrorStmt("Records must have at least one field.", stmt)
+	}
+
+	// invalid fields are left out to avoid follow-up errors in variables of this type
+	fields := make([]parser.FuncParam, 0, len(stmt.Fields))
+	names := make([]string, 0, len(stmt.Fields))
+	for _, f := range stmt.Fields {
+		if slices.Contains(names, f.Name.Lexeme) {
+			a.errors = append(a.errors, a.newErrorTk("Duplicate field name.", f.Name))
+			continue
+		}
+		names = append(names, f.Name.Lexeme)
+		switch f.Type.DataType {
+		case parser.DTNumber, parser.DTString, parser.DTBool:
+			fields = append(fields, f)
+		default:
+			a.errors = append(a.errors, a.newErrorTk("Record fields must be numbers, strings or booleans.", f.Type))
+		}
+	}
+
+	a.records[stmt.Name.Lexeme] = &Record{
+		Name:   stmt.Name,
+		Fields: fields,
+	}
+	return nil
+}
+
+// recordType returns the record type of the variable declared by stmt or nil if it is not a record variable.
+func (a *analyzer) recordType(stmt *parser.StmtVarDecl) (*Record, bool, error) {
+	if stmt.Type.Type == parser.TkIdentifier {
+		list := strings.HasSuffix(string(stmt.DataType), "[]")
+		record, ok := a.records[strings.TrimSuffix(string(stmt.DataType), "[]")]
+		if !ok {
+			return nil, false, a.newErrorTk("Unknown data type.", stmt.Type)
+		}
+		return record, list, nil
+	}
+	if stmt.DataType == "" {
+		if v := a.recordVariable(stmt.Value); v != nil {
+			if v.list {
+				return nil, false, a.newErrorExpr("Expected a list initializer.", stmt.Value)
+			}
+			return v.record, false, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// recordVariable returns the record variable referenced by expr or nil if expr is not the name of a record variable.
+func (a *analyzer) recordVariable(expr parser.Expr) *recordVariable {
+	ident, ok := expr.(*parser.ExprIdentifier)
+	if !ok {
+		return nil
+	}
+	return a.recordVariables[a.resolveLocal(ident.Name).Lexeme]
+}
+
+func (a *analyzer) declareRecordVariable(stmt *parser.StmtVarDecl, record *Record, list bool) error {
+	local := len(a.scopes) > 0
+	if local {
+		if a.unreachable {
+			a.newWarningStmt("Unreachable code.", stmt)
+		}
+		if err := a.assertNotDeclaredLocal(stmt.Name); err != nil {
+			return err
+		}
+	} else {
+		if err := a.assertNotDeclared(stmt.Name); err != nil {
+			return err
+		}
+		a.scopeName = stmt.Name.Lexeme
+		a.beginStatement()
+	}
+
+	if list {
+		if stmt.Value != nil {
+			return a.newErrorExpr("Lists of records cannot be initialized with values.", stmt.Value)
+		}
+	}
+
+	var values []parser.Expr
+	if stmt.Value != nil {
+		var err error
+		values, err = a.recordValues(stmt.Value, record, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	name := stmt.Name
+	if local {
+		name.Lexeme = a.uniqueHiddenName(stmt.Name.Lexeme)
+		a.scopes[len(a.scopes)-1][stmt.Name.Lexeme] = name.Lexeme
+	}
+	variable := &recordVariable{
+		Name:   name,
+		source: stmt.Name,
+		record: record,
+		list:   list,
+		fields: make([]string, 0, len(record.Fields)),
+	}
+
+	initializers := a.hoisted
+	if !local {
+		a.variableInitializers = append(a.variableInitializers, initializers...)
+		initializers = nil
+	}
+	for i, f := range record.Fields {
+		field := stmt.Name
+		field.Lexeme = fmt.Sprintf("%s.%s", stmt.Name.Lexeme, f.Name.Lexeme)
+		decl := &parser.StmtVarDecl{
+			Name:     field,
+			Type:     f.Type,
+			DataType: f.Type.DataType,
+		}
+		if list {
+			decl.DataType += "[]"
+		}
+		if values != nil {
+			decl.AssignToken = stmt.AssignToken
+			decl.Value = values[i]
+		}
+
+		unreachable := a.unreachable
+		a.unreachable = false
+		a.beginStatement()
+		err := a.VisitVarDecl(decl)
+		a.unreachable = unreachable
+		if err != nil {
+			return err
+		}
+		if local {
+			initializers = append(initializers, a.hoisted...)
+		}
+
+		backing := a.resolveLocal(field).Lexeme
+		if list {
+			a.lists[backing].field = true
+		} else {
+			a.variables[backing].field = true
+		}
+		variable.fields = append(variable.fields, backing)
+	}
+	a.hoisted = initializers
+	a.recordVariables[name.Lexeme] = variable
+	return nil
+}
+
+func (a *analyzer) isRecordUsed(variable *recordVariable) bool {
+	for _, f := range variable.fields {
+		if v, ok := a.variables[f]; ok && v.used {
+			return true
+		}
+		if l, ok := a.lists[f]; ok && l.used {
+			return true
+		}
+	}
+	return false
+}
+
+// recordValues returns one expression for every field of record which evaluates to the value of the field in value.
+// overwritten contains the variables which are assigned before the last expression is evaluated.
+func (a *analyzer) recordValues(value parser.Expr, record *Record, overwritten []string) ([]parser.Expr, error) {
+	if v := a.recordVariable(value); v != nil && !v.list {
+		if v.record != record {
+			return nil, a.newErrorExpr(fmt.Sprintf("Wrong data type. Expected %s.", record.Name.Lexeme), value)
+		}
+		values := make([]parser.Expr, len(v.fields))
+		for i, f := range v.fields {
+			values[i] = a.recordField(f, value)
+		}
+		return values, nil
+	}
+
+	if call, ok := value.(*parser.ExprFuncCall); ok && call.Name.Lexeme == "lists.get" && len(call.Parameters) == 2 {
+		if v := a.recordVariable(call.Parameters[0]); v != nil && v.list {
+			if v.record != record {
+				return nil, a.newErrorExpr(fmt.Sprintf("Wrong data type. Expected %s[].", record.Name.Lexeme), call.Parameters[0])
+			}
+			index, err := a.reusableIndex(call.Parameters[1], overwritten...)
+			if err != nil {
+				return nil, err
+			}
+			values := make([]parser.Expr, len(v.fields))
+			for i, f := range v.fields {
+				values[i] = &parser.ExprFuncCall{
+					Name:       call.Name,
+					Parameters: []parser.Expr{a.recordField(f, call.Parameters[0]), index()},
+					CloseParen: call.CloseParen,
+				}
+			}
+			return values, nil
+		}
+	}
+
+	return nil, a.newErrorExpr(fmt.Sprintf("Expected a %s value.", record.Name.Lexeme), value)
+}
+
+// recordField returns an identifier referencing the backing variable or list of a field at the position of expr.
+func (a *analyzer) recordField(name string, expr parser.Expr) *parser.ExprIdentifier {
+	start, end := expr.Position()
+	token := parser.Token{
+		Type:   parser.TkIdentifier,
+		Lexeme: name,
+		Pos:    start,
+		EndPos: end,
+	}
+	return &parser.ExprIdentifier{
+		Name: token,
+	}
+}
+
+// reusableIndex returns a function which creates expressions evaluating to the value of index.
+// Indices which are neither literals nor variables are evaluated only once and stored in a hidden variable.
+// The same applies to variables in overwritten, which are assigned before the last use of the index.
+func (a *analyzer) reusableIndex(index parser.Expr, overwritten ...string) (func() parser.Expr, error) {
+	err := index.Accept(a)
+	if err != nil {
+		return nil, err
+	}
+	if index.Type() != parser.DTNumber {
+		return nil, a.newErrorExpr("Wrong data type. Expected number.", index)
+	}
+	return a.reusableValue(index, "index", overwritten...), nil
+}
+
+// reusableValue returns a function which creates expressions evaluating to the value of expr.
+// Values which are neither literals nor variables are evaluated only once and stored in a hidden variable.
+// The same applies to variables in overwritten, which are assigned before the last use of the value.
+func (a *analyzer) reusableValue(expr parser.Expr, name string, overwritten ...string) func() parser.Expr {
+	switch e := expr.(type) {
+	case *parser.ExprLiteral:
+		return func() parser.Expr {
+			literal := *e
+			return &literal
+		}
+	case *parser.ExprIdentifier:
+		// Reads of return variables may be renamed by later calls and cannot be copied.
+		if !a.isPendingRead(e) && !slices.Contains(overwritten, a.resolveLocal(e.Name).Lexeme) {
+			return func() parser.Expr {
+				ident := *e
+				return &ident
+			}
+		}
+	}
+
+	start, _ := expr.Position()
+	hidden := a.hiddenToken(a.uniqueHiddenName(name), parser.Token{Pos: start})
+	a.hiddenVariable(hidden.Lexeme, expr.Type())
+	a.hoisted = append(a.hoisted, newAssignStmt(hidden, a.hoistCalls(expr)))
+	return func() parser.Expr {
+		return &parser.ExprIdentifier{
+			Name:       hidden,
+			ReturnType: expr.Type(),
+		}
+	}
+}
+
+// assignRecord replaces the assignment of a whole record with one assignment per field.
+func (a *analyzer) assignRecord(stmt *parser.StmtAssignment, variable *recordVariable) error {
+	if variable.list {
+		return a.newErrorTk("Lists of records cannot be assigned.", stmt.Variable)
+	}
+	if stmt.Operator.Type != parser.TkAssign {
+		return a.newErrorTk("Records can only be assigned with '='.", stmt.Operator)
+	}
+	// the fields are assigned one after another
+	values, err := a.recordValues(stmt.Value, variable.record, variable.fields)
+	if err != nil {
+		return err
+	}
+
+	assignments := make([]parser.Stmt, len(values))
+	for i, v := range values {
+		assignments[i] = &parser.StmtAssignment{
+			Variable: a.hiddenToken(variable.fields[i], stmt.Variable),
+			Operator: stmt.Operator,
+			Value:    v,
+		}
+	}
+	a.replaceStatement = true
+	return a.visitExpanded(assignments)
+}
+
+// recordListCall returns the list of records passed as the first argument to the list function called by stmt or nil.
+func (a *analyzer) recordListCall(stmt *parser.StmtCall) *recordVariable {
+	if !strings.HasPrefix(stmt.Name.Lexeme, "lists.") || len(stmt.Parameters) == 0 {
+		return nil
+	}
+	if v := a.recordVariable(stmt.Parameters[0]); v != nil && v.list {
+		return v
+	}
+	return nil
+}
+
+// callRecordList replaces a call of a list function on a list of records with one call per field.
+func (a *analyzer) callRecordList(stmt *parser.StmtCall, variable *recordVariable) error {
+	fn, ok := FuncCalls[stmt.Name.Lexeme]
+	if !ok {
+		if _, ok := ExprFuncCalls[stmt.Name.Lexeme]; ok {
+			return a.newErrorStmt("Only functions which don't return a value are allowed in this context.", stmt)
+		}
+		return a.newErrorTk("Unknown function.", stmt.Name)
+	}
+	if fn.Name == "lists.sort" {
+		return a.newErrorTk("Lists of records cannot be sorted.", stmt.Name)
+	}
+	params := fn.Signatures[0].Params
+	if len(stmt.Parameters) != len(params) {
+		return a.newErrorStmt("Wrong argument count.", stmt)
+	}
+
+	fieldArgs := make([][]parser.Expr, len(variable.fields))
+	for i, p := range params[1:] {
+		arg := stmt.Parameters[i+1]
+		switch p.Name {
+		case "index", "from", "to", "count":
+			index, err := a.reusableIndex(arg)
+			if err != nil {
+				return err
+			}
+			for f := range fieldArgs {
+				fieldArgs[f] = append(fieldArgs[f], index())
+			}
+		case "value":
+			values, err := a.recordValues(arg, variable.record, nil)
+			if err != nil {
+				return err
+			}
+			for f := range fieldArgs {
+				fieldArgs[f] = append(fieldArgs[f], values[f])
+			}
+		case "src":
+			src := a.recordVariable(arg)
+			if src == nil || !src.list || src.record != variable.record {
+				return a.newErrorExpr(fmt.Sprintf("Expected a list of type '%s'.", variable.record.Name.Lexeme), arg)
+			}
+			for f := range fieldArgs {
+				fieldArgs[f] = append(fieldArgs[f], a.recordField(src.fields[f], arg))
+			}
+		}
+	}
+
+	calls := make([]parser.Stmt, len(variable.fields))
+	for i, f := range variable.fields {
+		calls[i] = &parser.StmtCall{
+			Name:       stmt.Name,
+			Parameters: append([]parser.Expr{a.recordField(f, stmt.Parameters[0])}, fieldArgs[i]...),
+			CloseParen: stmt.CloseParen,
+		}
+	}
+	a.replaceStatement = true
+	return a.visitExpanded(calls)
+}
+
+// visitExpanded analyzes the statements which replace the current statement and hoists them.
+func (a *analyzer) visitExpanded(stmts []parser.Stmt) error {
+	unreachable := a.unreachable
+	a.unreachable = false
+	defer func() {
+		a.unreachable = unreachable
+	}()
+
+	hoisted := a.hoisted
+	for _, s := range stmts {
+		a.hoisted = make([]parser.Stmt, 0)
+		a.pendingReads = make([]*pendingRead, 0)
+		err := s.Accept(a)
+		if err != nil {
+			return err
+		}
+		hoisted = append(hoisted, a.hoisted...)
+		hoisted = append(hoisted, s)
+	}
+	a.hoisted = hoisted
+	return nil
+}
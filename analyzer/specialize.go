@@ -0,0 +1,206 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/juho05/embe/parser"
+)
+
+// Scratch can only pass numbers, strings and booleans to procedures. Functions with list or image parameters
+// are therefore used as templates: every distinct combination of lists and images at a call site creates a copy of the
+// function in which the parameters refer directly to the passed variables.
+
+type specialization struct {
+	decl     *parser.StmtFuncDecl
+	fn       *Function
+	bindings map[string]string
+}
+
+func isReferenceType(dataType parser.DataType) bool {
+	return dataType == parser.DTImage || strings.HasSuffix(string(dataType), "[]")
+}
+
+func hasReferenceParams(params []parser.FuncParam) bool {
+	for _, p := range params {
+		if isReferenceType(p.Type.DataType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *analyzer) declareTemplate(stmt *parser.StmtFuncDecl) {
+	fn := &Function{
+		Name:            stmt.Name,
+		Params:          stmt.Params,
+		Warp:            stmt.Warp,
+		StartLine:       stmt.StartLine,
+		EndLine:         stmt.EndLine,
+		template:        stmt,
+		specializations: make(map[string]*Function),
+	}
+	if stmt.ReturnType.Type == parser.TkType {
+		fn.ReturnType = stmt.ReturnType.DataType
+	}
+	a.functions[stmt.Name.Lexeme] = fn
+
+	names := make([]string, 0, len(stmt.Params))
+	for _, p := range stmt.Params {
+		if slices.Contains(names, p.Name.Lexeme) {
+			a.errors = append(a.errors, a.newErrorTk("Duplicate parameter name.", p.Name))
+		}
+		names = append(names, p.Name.Lexeme)
+		if p.Type.DataType == "image[]" {
+			a.errors = append(a.errors, a.newErrorTk("Image lists are not supported.", p.Type))
+		}
+	}
+}
+
+// checkTemplate analyzes a copy of the template fn with placeholder variables for its list and image parameters,
+// so that problems in its body are reported even if it is never called. Everything declared by the analysis is discarded.
+func (a *analyzer) checkTemplate(fn *Function) {
+	variables, lists, functions := maps.Clone(a.variables), maps.Clone(a.lists), maps.Clone(a.functions)
+	recordVariables, specializationCount := maps.Clone(a.recordVariables), len(a.specializations)
+	errorCount, warningCount := len(a.errors), len(a.warnings)
+
+	args := make([]parser.Expr, len(fn.Params))
+	for i, p := range fn.Params {
+		if !isReferenceType(p.Type.DataType) {
+			continue
+		}
+		if p.Type.DataType == "image[]" {
+			return
+		}
+		name := a.hiddenToken(fmt.Sprintf("$%s.%s", fn.Name.Lexeme, p.Name.Lexeme), p.Name)
+		if p.Type.DataType == parser.DTImage {
+			a.hiddenVariable(name.Lexeme, parser.DTImage)
+		} else {
+			a.lists[name.Lexeme] = &List{
+				Name:        name,
+				DataType:    p.Type.DataType,
+				placeholder: true,
+			}
+		}
+		args[i] = &parser.ExprIdentifier{
+			Name: name,
+		}
+	}
+
+	spec, _, err := a.specialize(fn, args)
+	if err == nil {
+		s := a.specializations[specializationCount]
+		a.scopes = []map[string]string{s.bindings}
+		err = a.analyzeFunction(s.decl, s.fn)
+		a.scopes = nil
+	}
+	if err != nil {
+		a.errors = append(a.errors, err)
+	}
+
+	a.variables, a.lists, a.functions = variables, lists, functions
+	a.recordVariables, a.specializations = recordVariables, a.specializations[:specializationCount]
+	for key, f := range fn.specializations {
+		if f == spec {
+			delete(fn.specializations, key)
+		}
+	}
+	a.errors = removeDuplicates(a.errors, errorCount)
+	a.warnings = removeDuplicates(a.warnings, warningCount)
+}
+
+// specialize returns the copy of the template fn for the list and image variables in args and the remaining arguments.
+func (a *analyzer) specialize(fn *Function, args []parser.Expr) (*Function, []parser.Expr, error) {
+	bindings := make(map[string]string)
+	names := make([]string, 0, len(args))
+	values := make([]parser.Expr, 0, len(args))
+	valueParams := make([]parser.FuncParam, 0, len(args))
+	for i, p := range fn.Params {
+		if !isReferenceType(p.Type.DataType) {
+			values = append(values, args[i])
+			valueParams = append(valueParams, p)
+			continue
+		}
+
+		ident, ok := args[i].(*parser.ExprIdentifier)
+		if !ok {
+			return nil, nil, a.newErrorExpr(fmt.Sprintf("Expected the name of a %s variable.", p.Type.DataType), args[i])
+		}
+		err := ident.Accept(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ident.Type() != p.Type.DataType {
+			return nil, nil, a.newErrorExpr(fmt.Sprintf("Expected %s parameter '%s'.", p.Type.DataType, p.Name.Lexeme), ident)
+		}
+		bindings[p.Name.Lexeme] = ident.Name.Lexeme
+		names = append(names, ident.Name.Lexeme)
+	}
+
+	key := strings.Join(names, ", ")
+	if spec, ok := fn.specializations[key]; ok {
+		return spec, values, nil
+	}
+
+	decl := parser.CloneStmt(fn.template).(*parser.StmtFuncDecl)
+	decl.Name.Lexeme = fmt.Sprintf("%s[%s]", fn.Name.Lexeme, key)
+	decl.Params = valueParams
+	spec := a.declareFunction(decl)
+	spec.used = true
+	fn.specializations[key] = spec
+	a.specializations = append(a.specializations, &specialization{
+		decl:     decl,
+		fn:       spec,
+		bindings: bindings,
+	})
+	return spec, values, nil
+}
+
+// analyzeSpecializations analyzes the bodies of all specializations and replaces the templates in statements with them.
+func (a *analyzer) analyzeSpecializations(statements []parser.Stmt) []parser.Stmt {
+	// specializations can create new specializations
+	for i := 0; i < len(a.specializations); i++ {
+		s := a.specializations[i]
+		errorCount, warningCount := len(a.errors), len(a.warnings)
+
+		a.scopes = []map[string]string{s.bindings}
+		err := a.analyzeFunction(s.decl, s.fn)
+		if err != nil {
+			a.errors = append(a.errors, err)
+		}
+		a.scopes = nil
+
+		// report problems in the template only once
+		a.errors = removeDuplicates(a.errors, errorCount)
+		a.warnings = removeDuplicates(a.warnings, warningCount)
+	}
+
+	newStatements := make([]parser.Stmt, 0, len(statements)+len(a.specializations))
+	for _, stmt := range statements {
+		if f, ok := stmt.(*parser.StmtFuncDecl); ok && a.functions[f.Name.Lexeme] != nil && a.functions[f.Name.Lexeme].template == f {
+			continue
+		}
+		newStatements = append(newStatements, stmt)
+	}
+	for _, s := range a.specializations {
+		newStatements = append(newStatements, s.decl)
+	}
+	return newStatements
+}
+
+func removeDuplicates(errs []error, start int) []error {
+	unique := errs[:start]
+errors:
+	for _, err := range errs[start:] {
+		for _, u := range unique {
+			if u == err {
+				continue errors
+			}
+		}
+		unique = append(unique, err)
+	}
+	return unique
+}
@@ -0,0 +1,154 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/juho05/embe/parser"
+)
+
+// stringHelpers contains the functions of the strings namespace which are implemented in helpers/strings.mb.
+// Calls with constant arguments are folded by the const calculator instead.
+var stringHelpers = []string{
+	"strings.substring",
+	"strings.indexOf",
+	"strings.split",
+	"strings.replace",
+	"strings.toUpper",
+	"strings.toLower",
+	"strings.trim",
+	"strings.repeat",
+	"strings.toFixed",
+}
+
+// maxFoldedLength is the length of the longest string which strings.repeat creates at compile time.
+// Longer strings are created by the helper function when the program runs.
+const maxFoldedLength = 10000
+
+// maxFoldedDecimals is the largest number of decimals which strings.toFixed formats at compile time.
+const maxFoldedDecimals = 100
+
+// canFoldString reports whether the call of a helper function with constant arguments can be calculated at compile time.
+// The size of the result is only known for literal arguments and constants with literal values.
+func (a *analyzer) canFoldString(expr *parser.ExprFuncCall) bool {
+	switch expr.Name.Lexeme {
+	case "strings.repeat":
+		if len(expr.Parameters) != 2 {
+			return false
+		}
+		str, ok := a.literalValue(expr.Parameters[0])
+		if !ok {
+			return false
+		}
+		count, ok := a.literalValue(expr.Parameters[1])
+		return ok && repeatFits(str.(string), count.(float64))
+	case "strings.toFixed":
+		if len(expr.Parameters) != 2 {
+			return false
+		}
+		decimals, ok := a.literalValue(expr.Parameters[1])
+		return ok && decimals.(float64) <= maxFoldedDecimals
+	}
+	return true
+}
+
+// repeatFits reports whether str repeated count times is at most maxFoldedLength bytes long.
+func repeatFits(str string, count float64) bool {
+	return str == "" || math.Round(count)*float64(len(str)) <= maxFoldedLength
+}
+
+// foldStringFunc calculates the result of the helper function name like helpers/strings.mb.
+func foldStringFunc(name string, params []any) (any, bool) {
+	switch name {
+	case "strings.substring":
+		return substring([]rune(params[0].(string)), params[1].(float64), params[2].(float64)), true
+	case "strings.indexOf":
+		return float64(indexOfFold([]rune(params[0].(string)), []rune(params[1].(string))) + 1), true
+	case "strings.replace":
+		str, old := []rune(params[0].(string)), []rune(params[1].(string))
+		if len(old) == 0 {
+			return string(str), true
+		}
+		var result strings.Builder
+		for {
+			index := indexOfFold(str, old)
+			if index < 0 {
+				break
+			}
+			result.WriteString(string(str[:index]))
+			result.WriteString(params[2].(string))
+			str = str[index+len(old):]
+		}
+		result.WriteString(string(str))
+		return result.String(), true
+	case "strings.toUpper":
+		return strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' {
+				return r - 'a' + 'A'
+			}
+			return r
+		}, params[0].(string)), true
+	case "strings.toLower":
+		return strings.Map(func(r rune) rune {
+			if r >= 'A' && r <= 'Z' {
+				return r - 'A' + 'a'
+			}
+			return r
+		}, params[0].(string)), true
+	case "strings.trim":
+		return strings.Trim(params[0].(string), " "), true
+	case "strings.repeat":
+		str, count := params[0].(string), params[1].(float64)
+		if str == "" || count < 1 {
+			return "", true
+		}
+		if !repeatFits(str, count) {
+			return nil, false
+		}
+		return strings.Repeat(str, int(math.Round(count))), true
+	case "strings.toFixed":
+		n := params[0].(float64)
+		decimals := math.Max(0, math.Round(params[1].(float64)))
+		if decimals > maxFoldedDecimals {
+			return nil, false
+		}
+		digits := fmt.Sprint(math.Round(math.Abs(n) * math.Pow(10, decimals)))
+		sign := ""
+		if n < 0 && digits != "0" {
+			sign = "-"
+		}
+		if decimals == 0 {
+			return sign + digits, true
+		}
+		for len(digits) <= int(decimals) {
+			digits = "0" + digits
+		}
+		point := len(digits) - int(decimals)
+		return sign + digits[:point] + "." + digits[point:], true
+	}
+	return nil, false
+}
+
+func substring(str []rune, from, to float64) string {
+	start := int(math.Max(from, 1)) - 1
+	end := int(math.Min(to, float64(len(str))))
+	if start >= end {
+		return ""
+	}
+	return string(str[start:end])
+}
+
+// indexOfFold returns the index of the first occurrence of substr in str ignoring case or -1.
+func indexOfFold(str, substr []rune) int {
+outer:
+	for i := 0; i <= len(str)-len(substr); i++ {
+		for j, r := range substr {
+			if !strings.EqualFold(string(str[i+j]), string(r)) {
+				continue outer
+			}
+		}
+		return i
+	}
+	return -1
+}
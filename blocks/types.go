@@ -335,5 +335,6 @@ const (
 // Event Broadcast
 const (
 	BroadcastEvent         BlockType = "event_broadcast"
+	BroadcastEventAndWait  BlockType = "event_broadcastandwait"
 	EventBroadcastReceived BlockType = "event_whenbroadcastreceived"
 )
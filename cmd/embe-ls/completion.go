@@ -21,6 +21,8 @@ var snippets = map[string]string{
 	"func declaration":  "func ${1:name}($2):\n  $0",
 	"event declaration": "event ${1:name}",
 	"include":           "#include \"$0\"",
+	"include library":   "#include <$0>",
+	"import":            "import \"$1\" as ${2:name}",
 	"undefine":          "#undef ${1:NAME}",
 	"define NAME":       "#define ${1:NAME}",
 	"define NAME VALUE": "#define ${1:NAME} ${2:value}",
@@ -30,7 +32,7 @@ var snippets = map[string]string{
 }
 
 var keywords = []string{
-	"if", "elif", "else", "while", "for", "var", "event", "#include", "#define", "#undef", "#ifdef", "#ifndef", "#endif",
+	"if", "elif", "else", "match", "case", "while", "for", "in", "break", "continue", "return", "await", "var", "type", "enum", "event", "import", "export", "#include", "#define", "#undef", "#if", "#ifdef", "#ifndef", "#elif", "#else", "#endif", "#error", "#warning", "#pragma",
 }
 
 var types = []string{
@@ -131,6 +133,9 @@ func (d *Document) getCompletions(item string, line int) []protocol.CompletionIt
 	varCompletionType := protocol.CompletionItemKindVariable
 	parameters := make(map[string]struct{})
 	for _, f := range d.functions {
+		if analyzer.IsHelper(f) {
+			continue
+		}
 		if line >= f.StartLine && line <= f.EndLine {
 			for _, p := range f.Params {
 				detail := fmt.Sprintf("var %s: %s", p.Name.Lexeme, p.Type.DataType)
@@ -145,6 +150,9 @@ func (d *Document) getCompletions(item string, line int) []protocol.CompletionIt
 	}
 
 	for _, f := range d.functions {
+		if analyzer.IsHelper(f) {
+			continue
+		}
 		if f.Name.LineAfterInclude < line && strings.HasPrefix(f.Name.Lexeme, item) {
 			if _, ok := parameters[f.Name.Lexeme]; ok {
 				continue
@@ -157,6 +165,9 @@ func (d *Document) getCompletions(item string, line int) []protocol.CompletionIt
 				detail += p.Name.Lexeme + ": " + string(p.Type.DataType)
 			}
 			detail += ")"
+			if f.ReturnType != "" {
+				detail += " : " + string(f.ReturnType)
+			}
 			completions = append(completions, protocol.CompletionItem{
 				Label:  strings.TrimPrefix(f.Name.Lexeme, base),
 				Kind:   &funcCompletionType,
@@ -181,7 +192,7 @@ func (d *Document) getCompletions(item string, line int) []protocol.CompletionIt
 
 	for _, v := range d.variables {
 		if v.Name.LineAfterInclude < line && strings.HasPrefix(v.Name.Lexeme, item) {
-			if _, ok := parameters[v.Name.Lexeme]; ok {
+			if _, ok := parameters[v.Name.Lexeme]; ok || strings.HasPrefix(v.Name.Lexeme, "$") {
 				continue
 			}
 			detail := fmt.Sprintf("var %s: %s", v.Name.Lexeme, v.DataType)
@@ -222,6 +233,15 @@ func (d *Document) getCompletions(item string, line int) []protocol.CompletionIt
 		}
 	}
 
+	for _, name := range parser.BuiltinMacros {
+		if strings.HasPrefix(name, item) {
+			completions = append(completions, protocol.CompletionItem{
+				Label: strings.TrimPrefix(name, base),
+				Kind:  &constCompletionType,
+			})
+		}
+	}
+
 	for _, d := range d.defines.GetDefines(parser.Position{Line: line}) {
 		if strings.HasPrefix(d.Name.Lexeme, item) {
 			detail := d.String()
@@ -7,18 +7,34 @@ import (
 	"path/filepath"
 
 	"github.com/adrg/xdg"
+
+	"github.com/juho05/embe/parser"
 )
 
 var (
-	ConfLogFile     string
-	ConfLogLevel    string
-	ConfGLSPLogFile *string
+	ConfLogFile      string
+	ConfLogLevel     string
+	ConfGLSPLogFile  *string
+	ConfDefines      *parser.Defines
+	ConfIncludePaths []string
 )
 
+// configErrors contains the problems with the config file. They are written to the log once it is initialized.
+var configErrors []string
+
+// configError reports a problem with the config file.
+func configError(format string, a ...any) {
+	message := fmt.Sprintf(format, a...)
+	fmt.Fprintln(os.Stderr, message)
+	configErrors = append(configErrors, message)
+}
+
 type Config struct {
-	LogFile     string  `json:"log_file"`
-	LogLevel    string  `json:"log_level"`
-	GLSPLogFile *string `json:"lsp_log_file"`
+	LogFile      string   `json:"log_file"`
+	LogLevel     string   `json:"log_level"`
+	GLSPLogFile  *string  `json:"lsp_log_file"`
+	Defines      []string `json:"defines"`
+	IncludePaths []string `json:"include_paths"`
 }
 
 func loadConfig() {
@@ -32,7 +48,7 @@ func loadConfig() {
 	var config Config
 	err = json.NewDecoder(file).Decode(&config)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to decode config file: %s", err)
+		configError("Failed to decode config file: %s", err)
 		return
 	}
 
@@ -46,4 +62,13 @@ func loadConfig() {
 	if ConfGLSPLogFile != nil {
 		os.Remove(*ConfGLSPLogFile)
 	}
+
+	ConfDefines = parser.NewDefines()
+	for _, d := range config.Defines {
+		err = ConfDefines.Define(d)
+		if err != nil {
+			configError("Invalid define in config file: %s", err)
+		}
+	}
+	ConfIncludePaths = config.IncludePaths
 }
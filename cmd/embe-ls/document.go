@@ -74,6 +74,7 @@ func (d *Document) validate(notify glsp.NotifyFunc) {
 	innerDocumentsLock.RUnlock()
 
 	var errs []error
+	var warnings []error
 	var defines *parser.Defines
 	var files map[string][][]rune
 	var statements []parser.Stmt
@@ -109,19 +110,37 @@ func (d *Document) validate(notify glsp.NotifyFunc) {
 	d.tokens = make([]parser.Token, len(tokens))
 	copy(d.tokens, tokens)
 
-	tokens, files, defines, _, errs = parser.Preprocess(tokens, d.path, func(name string) (io.ReadCloser, error) {
+	tokens, files, defines, _, errs, warnings = parser.Preprocess(tokens, d.path, func(name string) (io.ReadCloser, error) {
 		if doc, ok := getDocument(pathToURI(name)); ok {
 			return &reader{
 				content: bytes.NewReader([]byte(doc.content)),
 			}, nil
 		}
 		return os.Open(name)
-	}, nil, nil)
+	}, nil, ConfDefines, ConfIncludePaths)
 	for f := range files {
 		if _, ok := diagnostics[f]; !ok {
 			diagnostics[f] = make([]protocol.Diagnostic, 0, 5)
 		}
 	}
+	for _, warning := range warnings {
+		if w, ok := warning.(parser.ParseError); ok {
+			diagnostics[w.Token.Pos.Path] = append(diagnostics[w.Token.Pos.Path], protocol.Diagnostic{
+				Range: protocol.Range{
+					Start: protocol.Position{
+						Line:      uint32(w.Token.Pos.Line),
+						Character: uint32(w.Token.Pos.Column),
+					},
+					End: protocol.Position{
+						Line:      uint32(w.Token.EndPos.Line),
+						Character: uint32(w.Token.EndPos.Column + 1),
+					},
+				},
+				Severity: &severityWarning,
+				Message:  w.Message,
+			})
+		}
+	}
 	if len(errs) > 0 {
 		for _, err := range errs {
 			if e, ok := err.(parser.ParseError); ok {
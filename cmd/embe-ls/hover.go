@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -37,6 +38,9 @@ func textDocumentHover(context *glsp.Context, params *protocol.HoverParams) (*pr
 
 functions:
 	for _, f := range document.functions {
+		if analyzer.IsHelper(f) {
+			continue
+		}
 		if int(params.Position.Line) >= f.StartLine && int(params.Position.Line) <= f.EndLine {
 			for _, p := range f.Params {
 				if p.Name.Lexeme == identifierName {
@@ -72,11 +76,22 @@ functions:
 		} else if cv, ok := document.variables[token.Lexeme]; ok {
 			signature = fmt.Sprintf("var %s: %s", cv.Name.Lexeme, cv.DataType)
 		} else if l, ok := document.lists[token.Lexeme]; ok {
-			signature = fmt.Sprintf("var %s: %s", l.Name.Lexeme, l.DataType)
+			keyword := "var"
+			if l.Constant {
+				keyword = "const"
+			}
+			dataType := string(l.DataType)
+			if l.Columns > 0 {
+				dataType = fmt.Sprintf("%s[%d][%d]", strings.TrimSuffix(dataType, "[]"), l.Rows, l.Columns)
+			}
+			signature = fmt.Sprintf("%s %s: %s", keyword, l.Name.Lexeme, dataType)
 		} else if c, ok := document.constants[token.Lexeme]; ok {
 			signature = fmt.Sprintf("const %s: %s = %s", c.Name.Lexeme, c.Type, toString(c.Value))
 		} else if cf, ok := document.functions[token.Lexeme]; ok {
 			signature = "func " + cf.Name.Lexeme + "("
+			if cf.Warp {
+				signature = "@warp " + signature
+			}
 			for i, p := range cf.Params {
 				if i > 0 {
 					signature += ", "
@@ -84,10 +99,23 @@ functions:
 				signature += p.Name.Lexeme + ": " + string(p.Type.DataType)
 			}
 			signature += ")"
+			if cf.ReturnType != "" {
+				signature += " : " + string(cf.ReturnType)
+			}
 		} else if d, ok := document.defines.GetDefine(token.Lexeme, token.Pos); ok {
 			signature = d.String()
 		} else if ce, ok := document.events[token.Lexeme]; ok {
 			signature = fmt.Sprintf("event %s", ce.Name.Lexeme)
+			if len(ce.Params) > 0 {
+				signature += "("
+				for i, p := range ce.Params {
+					if i > 0 {
+						signature += ", "
+					}
+					signature += p.Name.Lexeme + ": " + string(p.Type.DataType)
+				}
+				signature += ")"
+			}
 		}
 	}
 
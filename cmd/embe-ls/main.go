@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/pflag"
 	"github.com/tliron/glsp"
@@ -9,6 +10,8 @@ import (
 	"github.com/tliron/glsp/server"
 	"github.com/tliron/kutil/logging"
 	_ "github.com/tliron/kutil/logging/simple"
+
+	"github.com/juho05/embe/parser"
 )
 
 var (
@@ -21,7 +24,14 @@ var handler protocol.Handler
 func main() {
 	loadConfig()
 	initLog()
+	// the errors have already been written to stderr
+	if logFile != os.Stderr {
+		for _, e := range configErrors {
+			Error("%s", e)
+		}
+	}
 	Info("Starting %s %s...", name, version)
+	parser.Version = version
 	glspLogLevel := 0
 	if ConfGLSPLogFile != nil {
 		glspLogLevel = 2
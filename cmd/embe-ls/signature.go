@@ -72,8 +72,9 @@ func textDocumentSignatureHelp(context *glsp.Context, params *protocol.Signature
 		}
 		signatures = []analyzer.Signature{
 			{
-				FuncName: f.Name.Lexeme,
-				Params:   params,
+				FuncName:   f.Name.Lexeme,
+				Params:     params,
+				ReturnType: f.ReturnType,
 			},
 		}
 	} else {
@@ -66,7 +66,7 @@ func printError(err error, lines [][]rune, includedFiles map[string][][]rune) {
 			Path:   e.Pos.Path,
 		}, false))
 	case parser.ParseError:
-		fmt.Fprintln(stderr, generateErrorText(e.Message, lines, includedFiles, e.Token.Pos, e.Token.EndPos, false))
+		fmt.Fprintln(stderr, generateErrorText(e.Message, lines, includedFiles, e.Token.Pos, e.Token.EndPos, e.Warning))
 	case analyzer.AnalyzerError:
 		fmt.Fprintln(stderr, generateErrorText(e.Message, lines, includedFiles, e.Start, e.End, e.Warning))
 	case generator.GenerateError:
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,7 +24,12 @@ var (
 func main() {
 	if len(os.Args) == 1 {
 		fmt.Fprintf(stderr, "Compile embe source code to .mblock files.\n\n")
-		fmt.Fprintf(stderr, "USAGE:\n  %s <files...>\n\n", os.Args[0])
+		fmt.Fprintf(stderr, "USAGE:\n  %s [options] <files...>\n\n", os.Args[0])
+		fmt.Fprintln(stderr, "OPTIONS:")
+		fmt.Fprintln(stderr, "  -D NAME[=value]  define the preprocessor macro NAME (default value: 1)")
+		fmt.Fprintln(stderr, "  -U NAME          undefine the preprocessor macro NAME")
+		fmt.Fprintln(stderr, "  -I DIR           search DIR for included and imported files")
+		fmt.Fprintln(stderr)
 		fmt.Fprintln(stderr, "COMMANDS:")
 		fmt.Fprintln(stderr, "  docs       open the embe documentation in a browser")
 		fmt.Fprintln(stderr, "  uninstall  uninstall embe")
@@ -48,28 +54,35 @@ func main() {
 
 func run() {
 	versionCheck(true, false)
+	parser.Version = version
+
+	inFiles, defines, includePaths, err := parseArgs(os.Args[1:])
+	if err != nil {
+		printError(err, nil, nil)
+		os.Exit(1)
+	}
 
 	var inFileNameBase string
 
-	allBlocks := make([]map[string]*blocks.Block, 0, len(os.Args)-1)
-	allDefinitions := make([]analyzer.Definitions, 0, len(os.Args)-1)
+	allBlocks := make([]map[string]*blocks.Block, 0, len(inFiles))
+	allDefinitions := make([]analyzer.Definitions, 0, len(inFiles))
 
 	var error bool
-	for i := 1; i < len(os.Args); i++ {
-		fmt.Printf("Compiling %s...\n", os.Args[i])
-		file, err := os.Open(os.Args[i])
+	for i, inFile := range inFiles {
+		fmt.Printf("Compiling %s...\n", inFile)
+		file, err := os.Open(inFile)
 		if err != nil {
 			printError(err, nil, nil)
 			error = true
 			continue
 		}
-		if i == 1 {
+		if i == 0 {
 			inFileNameBase = filepath.Base(file.Name())
 		}
 
 		var path string
-		if !filepath.IsAbs(os.Args[i]) {
-			path, err = filepath.Abs(os.Args[i])
+		if !filepath.IsAbs(inFile) {
+			path, err = filepath.Abs(inFile)
 			if err != nil {
 				panic(err)
 			}
@@ -88,7 +101,10 @@ func run() {
 			continue
 		}
 
-		tokens, files, _, _, errs := parser.Preprocess(tokens, path, nil, nil, nil)
+		tokens, files, _, _, errs, warnings := parser.Preprocess(tokens, path, nil, nil, defines, includePaths)
+		for _, w := range warnings {
+			printError(w, lines, files)
+		}
 		if len(errs) > 0 {
 			for _, err := range errs {
 				printError(err, lines, files)
@@ -151,6 +167,42 @@ func run() {
 	}
 }
 
+// parseArgs separates the input files from the -D, -U and -I options and applies the options in order.
+func parseArgs(args []string) ([]string, *parser.Defines, []string, error) {
+	files := make([]string, 0, len(args))
+	defines := parser.NewDefines()
+	includePaths := make([]string, 0)
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-D") && !strings.HasPrefix(args[i], "-U") && !strings.HasPrefix(args[i], "-I") {
+			files = append(files, args[i])
+			continue
+		}
+		option, value := args[i][:2], args[i][2:]
+		if value == "" {
+			i++
+			if i >= len(args) {
+				return nil, nil, nil, fmt.Errorf("Expected value after %s.", option)
+			}
+			value = args[i]
+		}
+		switch option {
+		case "-D":
+			err := defines.Define(value)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		case "-U":
+			defines.Undefine(value)
+		case "-I":
+			includePaths = append(includePaths, value)
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil, nil, errors.New("No input files.")
+	}
+	return files, defines, includePaths, nil
+}
+
 func printVersion() {
 	fmt.Println("embe", version)
 }
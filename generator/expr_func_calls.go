@@ -637,6 +637,12 @@ func exprFuncStringsContains(g *generator, expr *parser.ExprFuncCall) (*blocks.B
 }
 
 func exprFuncListsGet(g *generator, expr *parser.ExprFuncCall) (*blocks.Block, error) {
+	var equals *blocks.Block
+	if expr.Type() == parser.DTBool {
+		// boolean lists store "true" or "false"
+		equals = g.NewBlock(blocks.OpEquals, false)
+		g.parent = equals.ID
+	}
 	block := g.NewBlock(blocks.ListItem, false)
 	err := selectList(g, block, expr.Parameters[0])
 	if err != nil {
@@ -648,6 +654,12 @@ func exprFuncListsGet(g *generator, expr *parser.ExprFuncCall) (*blocks.Block, e
 	if err != nil {
 		return nil, err
 	}
+	if equals != nil {
+		equals.Next = nil
+		equals.Inputs["OPERAND1"] = []any{3, block.ID, []any{10, ""}}
+		equals.Inputs["OPERAND2"] = []any{1, []any{10, "true"}}
+		return equals, nil
+	}
 	return block, nil
 }
 
@@ -657,7 +669,7 @@ func exprFuncListsIndexOf(g *generator, expr *parser.ExprFuncCall) (*blocks.Bloc
 	if err != nil {
 		g.errors = append(g.errors, err)
 	}
-	block.Inputs["ITEM"], err = g.value(block.ID, expr.Parameters[1])
+	block.Inputs["ITEM"], err = g.stringValue(block.ID, expr.Parameters[1])
 	if err != nil {
 		return nil, err
 	}
@@ -679,7 +691,7 @@ func exprFuncListsContains(g *generator, expr *parser.ExprFuncCall) (*blocks.Blo
 	if err != nil {
 		g.errors = append(g.errors, err)
 	}
-	block.Inputs["ITEM"], err = g.value(block.ID, expr.Parameters[1])
+	block.Inputs["ITEM"], err = g.stringValue(block.ID, expr.Parameters[1])
 	if err != nil {
 		return nil, err
 	}
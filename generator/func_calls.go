@@ -1825,7 +1825,7 @@ func funcListsAppend(g *generator, stmt *parser.StmtCall) (*blocks.Block, error)
 	if err != nil {
 		g.errors = append(g.errors, err)
 	}
-	block.Inputs["ITEM"], err = g.value(block.ID, stmt.Parameters[1])
+	block.Inputs["ITEM"], err = g.stringValue(block.ID, stmt.Parameters[1])
 	if err != nil {
 		g.errors = append(g.errors, err)
 	}
@@ -1868,7 +1868,7 @@ func funcListsInsert(g *generator, stmt *parser.StmtCall) (*blocks.Block, error)
 	if err != nil {
 		g.errors = append(g.errors, err)
 	}
-	block.Inputs["ITEM"], err = g.value(block.ID, stmt.Parameters[2])
+	block.Inputs["ITEM"], err = g.stringValue(block.ID, stmt.Parameters[2])
 	if err != nil {
 		g.errors = append(g.errors, err)
 	}
@@ -1887,7 +1887,7 @@ func funcListsReplace(g *generator, stmt *parser.StmtCall) (*blocks.Block, error
 	if err != nil {
 		g.errors = append(g.errors, err)
 	}
-	block.Inputs["ITEM"], err = g.value(block.ID, stmt.Parameters[2])
+	block.Inputs["ITEM"], err = g.stringValue(block.ID, stmt.Parameters[2])
 	if err != nil {
 		g.errors = append(g.errors, err)
 	}
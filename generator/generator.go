@@ -94,7 +94,7 @@ func (g *generator) VisitFuncDecl(stmt *parser.StmtFuncDecl) error {
 		"tagName":          "mutation",
 		"children":         []any{},
 		"proccode":         fn.ProcCode,
-		"warp":             "false",
+		"warp":             strconv.FormatBool(fn.Warp),
 		"argumentids":      "[]",
 		"argumentnames":    "[]",
 		"argumentdefaults": "[]",
@@ -106,12 +106,15 @@ func (g *generator) VisitFuncDecl(stmt *parser.StmtFuncDecl) error {
 	}
 
 	g.definitions.Functions[stmt.Name.Lexeme] = &analyzer.Function{
-		Name:        stmt.Name,
-		Params:      stmt.Params,
-		ProcCode:    fn.ProcCode,
-		ArgumentIDs: fn.ArgumentIDs,
-		StartLine:   stmt.StartLine,
-		EndLine:     stmt.EndLine,
+		Name:           stmt.Name,
+		Params:         stmt.Params,
+		ReturnType:     fn.ReturnType,
+		ReturnVariable: fn.ReturnVariable,
+		Warp:           fn.Warp,
+		ProcCode:       fn.ProcCode,
+		ArgumentIDs:    fn.ArgumentIDs,
+		StartLine:      stmt.StartLine,
+		EndLine:        stmt.EndLine,
 	}
 
 	block.Inputs["custom_block"] = []any{1, prototype.ID}
@@ -162,6 +165,14 @@ func (g *generator) VisitEventDecl(stmt *parser.StmtEventDecl) error {
 	return nil
 }
 
+func (g *generator) VisitTypeDecl(stmt *parser.StmtTypeDecl) error {
+	return nil
+}
+
+func (g *generator) VisitEnumDecl(stmt *parser.StmtEnumDecl) error {
+	return nil
+}
+
 func (g *generator) VisitCall(stmt *parser.StmtCall) error {
 	if f, ok := g.definitions.Functions[stmt.Name.Lexeme]; ok {
 		block := g.NewBlock(blocks.ProceduresCall, false)
@@ -179,7 +190,7 @@ func (g *generator) VisitCall(stmt *parser.StmtCall) error {
 			"children":    []any{},
 			"proccode":    f.ProcCode,
 			"argumentids": "[]",
-			"warp":        "false",
+			"warp":        strconv.FormatBool(f.Warp),
 		}
 
 		if len(f.Params) > 0 {
@@ -195,7 +206,11 @@ func (g *generator) VisitCall(stmt *parser.StmtCall) error {
 		g.blockID = block.ID
 	} else {
 		e := g.definitions.Events[stmt.Name.Lexeme]
-		block := g.NewBlock(blocks.BroadcastEvent, false)
+		blockType := blocks.BroadcastEvent
+		if stmt.Await.Type == parser.TkAwait {
+			blockType = blocks.BroadcastEventAndWait
+		}
+		block := g.NewBlock(blockType, false)
 		block.Inputs["BROADCAST_INPUT"] = []any{1, []any{11, e.Name.Lexeme, e.ID}}
 		g.blockID = block.ID
 	}
@@ -227,7 +242,13 @@ func (g *generator) VisitAssignment(stmt *parser.StmtAssignment) error {
 		} else {
 			block = g.NewBlock(blocks.VariableSetTo, false)
 
-			value, err := g.value(block.ID, stmt.Value)
+			var value []any
+			var err error
+			if variable.DataType == parser.DTBool {
+				value, err = g.booleanValue(block.ID, stmt.Value)
+			} else {
+				value, err = g.value(block.ID, stmt.Value)
+			}
 			if err != nil {
 				return err
 			}
@@ -344,6 +365,35 @@ func (g *generator) VisitLoop(stmt *parser.StmtLoop) error {
 	return nil
 }
 
+func (g *generator) VisitReturn(stmt *parser.StmtReturn) error {
+	block, err := funcScriptStop("this script")(g, nil)
+	if err != nil {
+		return err
+	}
+	g.blockID = block.ID
+	return nil
+}
+
+func (g *generator) VisitMatch(stmt *parser.StmtMatch) error {
+	panic("Should never be called.")
+}
+
+func (g *generator) VisitImport(stmt *parser.StmtImport) error {
+	panic("Should never be called.")
+}
+
+func (g *generator) VisitRange(expr *parser.ExprRange) error {
+	panic("Should never be called.")
+}
+
+func (g *generator) VisitTernary(expr *parser.ExprTernary) error {
+	panic("Should never be called.")
+}
+
+func (g *generator) VisitLoopControl(stmt *parser.StmtLoopControl) error {
+	panic("Should never be called.")
+}
+
 func (g *generator) VisitIdentifier(expr *parser.ExprIdentifier) error {
 	if g.currentFunction != nil {
 		for _, p := range g.currentFunction.Params {
@@ -375,6 +425,14 @@ func (g *generator) VisitIdentifier(expr *parser.ExprIdentifier) error {
 	}
 
 	if variable, ok := g.definitions.Variables[expr.Name.Lexeme]; ok {
+		if variable.DataType == parser.DTBool {
+			// boolean variables store "true" or "false"
+			block := g.NewBlock(blocks.OpEquals, false)
+			block.Inputs["OPERAND1"] = []any{3, []any{12, variable.Name.Lexeme, variable.ID}, []any{10, ""}}
+			block.Inputs["OPERAND2"] = []any{1, []any{10, "true"}}
+			g.blockID = block.ID
+			return nil
+		}
 		g.variableName = variable.Name.Lexeme
 		return nil
 	}
@@ -510,6 +568,54 @@ func (g *generator) VisitBinary(expr *parser.ExprBinary) error {
 	return nil
 }
 
+func (g *generator) VisitInterpolation(expr *parser.ExprInterpolation) error {
+	parts := expr.Parts
+	if len(parts) == 1 {
+		parts = append(parts, &parser.ExprLiteral{
+			Token: parser.Token{
+				Type:     parser.TkLiteral,
+				DataType: parser.DTString,
+				Literal:  "",
+			},
+			ReturnType: parser.DTString,
+		})
+	}
+
+	block := g.NewBlock(blocks.OpJoin, false)
+
+	first, err := g.stringValue(block.ID, parts[0])
+	if err != nil {
+		g.errors = append(g.errors, err)
+	}
+	block.Inputs["STRING1"] = first
+
+	var second []any
+	if len(parts) == 2 {
+		second, err = g.stringValue(block.ID, parts[1])
+	} else {
+		second, err = g.value(block.ID, &parser.ExprInterpolation{
+			Start: expr.Start,
+			Parts: parts[1:],
+			End:   expr.End,
+		})
+	}
+	if err != nil {
+		g.errors = append(g.errors, err)
+	}
+	block.Inputs["STRING2"] = second
+
+	g.blockID = block.ID
+	return nil
+}
+
+// stringValue is like value but converts boolean expressions to strings.
+func (g *generator) stringValue(parent string, expr parser.Expr) ([]any, error) {
+	if expr.Type() == parser.DTBool {
+		return g.booleanValue(parent, expr)
+	}
+	return g.value(parent, expr)
+}
+
 func (g *generator) VisitGrouping(expr *parser.ExprGrouping) error {
 	return expr.Expr.Accept(g)
 }
@@ -593,6 +699,18 @@ func (g *generator) valueWithValidator(parent string, expr parser.Expr, validate
 	}
 }
 
+// booleanValue converts a boolean expression into a text input containing "true" or "false".
+func (g *generator) booleanValue(parent string, expr parser.Expr) ([]any, error) {
+	if literal, ok := expr.(*parser.ExprLiteral); ok {
+		return []any{1, []any{10, fmt.Sprintf("%v", literal.Token.Literal)}}, nil
+	}
+	value, err := g.value(parent, expr)
+	if err != nil {
+		return nil, err
+	}
+	return []any{3, value[1], []any{10, ""}}, nil
+}
+
 func intFromDT(dataType parser.DataType, valueIntOverride int) int {
 	if valueIntOverride != -1 {
 		return valueIntOverride
@@ -0,0 +1,7 @@
+// Package library contains the embe source files which can be included with '#include <name>'.
+package library
+
+import "embed"
+
+//go:embed *.mb
+var Files embed.FS
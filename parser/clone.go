@@ -0,0 +1,44 @@
+package parser
+
+import "reflect"
+
+// CloneStmt returns a deep copy of stmt which shares no nodes with the original.
+func CloneStmt(stmt Stmt) Stmt {
+	return deepCopy(reflect.ValueOf(stmt)).Interface().(Stmt)
+}
+
+func deepCopy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Elem().Type())
+		copied.Elem().Set(deepCopy(value.Elem()))
+		return copied
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(deepCopy(value.Elem()))
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.NumField(); i++ {
+			copied.Field(i).Set(deepCopy(value.Field(i)))
+		}
+		return copied
+	default:
+		return value
+	}
+}
@@ -0,0 +1,335 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+)
+
+// The expressions of #if and #elif directives are evaluated after all macros were replaced.
+// They can contain integers, strings, booleans, 'defined(NAME)' and the operators of embe expressions.
+// Identifiers which are not defined as macros evaluate to 0.
+
+type conditionEvaluator struct {
+	tokens  []Token
+	current int
+}
+
+// evaluateCondition evaluates the expression of the #if or #elif directive at the current position and skips it.
+func (p *preprocessor) evaluateCondition() bool {
+	directive := p.tokens[p.index]
+	start := p.index + 1
+	p.skipExpression()
+	tokens, err := p.resolveDefined(p.tokens[start : p.index+1])
+	if err != nil {
+		p.errors = append(p.errors, err)
+		return false
+	}
+	tokens = p.expand(tokens, nil)
+
+	e := &conditionEvaluator{
+		tokens: tokens,
+	}
+	if len(tokens) == 0 {
+		p.errors = append(p.errors, p.newErrorAt(fmt.Sprintf("Expected expression after %s.", directive.Lexeme), directive))
+		return false
+	}
+	value, err := e.or()
+	if err == nil && e.current < len(e.tokens) {
+		err = e.newError("Unexpected token.")
+	}
+	if err != nil {
+		p.errors = append(p.errors, err)
+		return false
+	}
+	result, err := e.truthy(value, tokens[0])
+	if err != nil {
+		p.errors = append(p.errors, err)
+		return false
+	}
+	return result
+}
+
+// resolveDefined replaces 'defined(NAME)' and 'defined NAME' with boolean literals.
+func (p *preprocessor) resolveDefined(tokens []Token) ([]Token, error) {
+	result := make([]Token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].Type != TkIdentifier || tokens[i].Lexeme != "defined" {
+			result = append(result, tokens[i])
+			continue
+		}
+		keyword := tokens[i]
+		parens := i+1 < len(tokens) && tokens[i+1].Type == TkOpenParen
+		nameIndex := i + 1
+		if parens {
+			nameIndex++
+		}
+		if nameIndex >= len(tokens) || tokens[nameIndex].Type != TkIdentifier {
+			return nil, p.newErrorAt("Expected name after 'defined'.", keyword)
+		}
+		i = nameIndex
+		if parens {
+			if i+1 >= len(tokens) || tokens[i+1].Type != TkCloseParen {
+				return nil, p.newErrorAt("Expected ')' after name.", tokens[nameIndex])
+			}
+			i++
+		}
+		keyword.Type = TkLiteral
+		keyword.DataType = DTBool
+		keyword.Literal = p.isDefined(tokens[nameIndex])
+		keyword.EndPos = tokens[i].EndPos
+		result = append(result, keyword)
+	}
+	return result, nil
+}
+
+func (e *conditionEvaluator) or() (any, error) {
+	left, err := e.and()
+	if err != nil {
+		return nil, err
+	}
+	for e.match(TkOr) {
+		operator := e.previous()
+		right, err := e.and()
+		if err != nil {
+			return nil, err
+		}
+		l, err := e.truthy(left, operator)
+		if err != nil {
+			return nil, err
+		}
+		r, err := e.truthy(right, operator)
+		if err != nil {
+			return nil, err
+		}
+		left = l || r
+	}
+	return left, nil
+}
+
+func (e *conditionEvaluator) and() (any, error) {
+	left, err := e.equality()
+	if err != nil {
+		return nil, err
+	}
+	for e.match(TkAnd) {
+		operator := e.previous()
+		right, err := e.equality()
+		if err != nil {
+			return nil, err
+		}
+		l, err := e.truthy(left, operator)
+		if err != nil {
+			return nil, err
+		}
+		r, err := e.truthy(right, operator)
+		if err != nil {
+			return nil, err
+		}
+		left = l && r
+	}
+	return left, nil
+}
+
+func (e *conditionEvaluator) equality() (any, error) {
+	left, err := e.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for e.match(TkEqual, TkNotEqual) {
+		operator := e.previous()
+		right, err := e.comparison()
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprintf("%T", left) != fmt.Sprintf("%T", right) {
+			return nil, newConditionError("Cannot compare values of different types.", operator)
+		}
+		left = (left == right) == (operator.Type == TkEqual)
+	}
+	return left, nil
+}
+
+func (e *conditionEvaluator) comparison() (any, error) {
+	left, err := e.term()
+	if err != nil {
+		return nil, err
+	}
+	for e.match(TkLess, TkLessEqual, TkGreater, TkGreaterEqual) {
+		operator := e.previous()
+		right, err := e.term()
+		if err != nil {
+			return nil, err
+		}
+		l, r, err := e.integers(left, right, operator)
+		if err != nil {
+			return nil, err
+		}
+		switch operator.Type {
+		case TkLess:
+			left = l < r
+		case TkLessEqual:
+			left = l <= r
+		case TkGreater:
+			left = l > r
+		case TkGreaterEqual:
+			left = l >= r
+		}
+	}
+	return left, nil
+}
+
+func (e *conditionEvaluator) term() (any, error) {
+	left, err := e.factor()
+	if err != nil {
+		return nil, err
+	}
+	for e.match(TkPlus, TkMinus) {
+		operator := e.previous()
+		right, err := e.factor()
+		if err != nil {
+			return nil, err
+		}
+		l, r, err := e.integers(left, right, operator)
+		if err != nil {
+			return nil, err
+		}
+		if operator.Type == TkPlus {
+			left = l + r
+		} else {
+			left = l - r
+		}
+	}
+	return left, nil
+}
+
+func (e *conditionEvaluator) factor() (any, error) {
+	left, err := e.unary()
+	if err != nil {
+		return nil, err
+	}
+	for e.match(TkMultiply, TkDivide, TkModulus) {
+		operator := e.previous()
+		right, err := e.unary()
+		if err != nil {
+			return nil, err
+		}
+		l, r, err := e.integers(left, right, operator)
+		if err != nil {
+			return nil, err
+		}
+		if operator.Type != TkMultiply && r == 0 {
+			return nil, newConditionError("Division by zero.", operator)
+		}
+		switch operator.Type {
+		case TkMultiply:
+			left = l * r
+		case TkDivide:
+			left = l / r
+		case TkModulus:
+			left = l % r
+		}
+	}
+	return left, nil
+}
+
+func (e *conditionEvaluator) unary() (any, error) {
+	if e.match(TkBang, TkMinus) {
+		operator := e.previous()
+		right, err := e.unary()
+		if err != nil {
+			return nil, err
+		}
+		if operator.Type == TkBang {
+			value, err := e.truthy(right, operator)
+			return !value, err
+		}
+		value, ok := right.(int64)
+		if !ok {
+			return nil, newConditionError("Expected integer operand.", operator)
+		}
+		return -value, nil
+	}
+	return e.primary()
+}
+
+func (e *conditionEvaluator) primary() (any, error) {
+	if e.match(TkLiteral) {
+		token := e.previous()
+		switch value := token.Literal.(type) {
+		case float64:
+			if value != math.Trunc(value) {
+				return nil, newConditionError("Only integers are allowed in preprocessor conditions.", token)
+			}
+			return int64(value), nil
+		default:
+			return value, nil
+		}
+	}
+
+	if e.match(TkIdentifier) {
+		return int64(0), nil
+	}
+
+	if e.match(TkOpenParen) {
+		value, err := e.or()
+		if err != nil {
+			return nil, err
+		}
+		if !e.match(TkCloseParen) {
+			return nil, e.newError("Expected ')' after expression.")
+		}
+		return value, nil
+	}
+
+	return nil, e.newError("Expected expression.")
+}
+
+func (e *conditionEvaluator) truthy(value any, operator Token) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	}
+	return false, newConditionError("Expected boolean or integer.", operator)
+}
+
+func (e *conditionEvaluator) integers(left, right any, operator Token) (int64, int64, error) {
+	l, lok := left.(int64)
+	r, rok := right.(int64)
+	if !lok || !rok {
+		return 0, 0, newConditionError("Expected integer operands.", operator)
+	}
+	return l, r, nil
+}
+
+func (e *conditionEvaluator) match(types ...TokenType) bool {
+	if e.current >= len(e.tokens) {
+		return false
+	}
+	for _, t := range types {
+		if e.tokens[e.current].Type == t {
+			e.current++
+			return true
+		}
+	}
+	return false
+}
+
+func (e *conditionEvaluator) previous() Token {
+	return e.tokens[e.current-1]
+}
+
+func (e *conditionEvaluator) newError(message string) error {
+	if e.current >= len(e.tokens) {
+		return newConditionError(message, e.tokens[len(e.tokens)-1])
+	}
+	return newConditionError(message, e.tokens[e.current])
+}
+
+func newConditionError(message string, token Token) error {
+	return ParseError{
+		Token:   token,
+		Message: message,
+	}
+}
@@ -9,6 +9,9 @@ type ExprVisitor interface {
 	VisitUnary(expr *ExprUnary) error
 	VisitBinary(expr *ExprBinary) error
 	VisitGrouping(expr *ExprGrouping) error
+	VisitRange(expr *ExprRange) error
+	VisitTernary(expr *ExprTernary) error
+	VisitInterpolation(expr *ExprInterpolation) error
 }
 
 type Expr interface {
@@ -171,3 +174,69 @@ func (e *ExprGrouping) Type() DataType {
 func (e *ExprGrouping) Position() (start, end Position) {
 	return e.OpenParen.Pos, e.CloseParen.Pos
 }
+
+// ExprRange is an inclusive range of numbers. It can only be used in for loops.
+type ExprRange struct {
+	Start      Expr
+	Operator   Token
+	End        Expr
+	ReturnType DataType
+}
+
+func (e *ExprRange) Accept(visitor ExprVisitor) error {
+	return visitor.VisitRange(e)
+}
+
+func (e *ExprRange) Type() DataType {
+	return e.ReturnType
+}
+
+func (e *ExprRange) Position() (start, end Position) {
+	start, _ = e.Start.Position()
+	_, end = e.End.Position()
+	return start, end
+}
+
+// ExprTernary evaluates to TrueValue if Condition is true and to FalseValue otherwise.
+type ExprTernary struct {
+	Condition    Expr
+	QuestionMark Token
+	TrueValue    Expr
+	Colon        Token
+	FalseValue   Expr
+	ReturnType   DataType
+}
+
+func (e *ExprTernary) Accept(visitor ExprVisitor) error {
+	return visitor.VisitTernary(e)
+}
+
+func (e *ExprTernary) Type() DataType {
+	return e.ReturnType
+}
+
+func (e *ExprTernary) Position() (start, end Position) {
+	start, _ = e.Condition.Position()
+	_, end = e.FalseValue.Position()
+	return start, end
+}
+
+// ExprInterpolation is a string literal with embedded expressions. Parts contains the string fragments
+// as literals and the embedded expressions in source order.
+type ExprInterpolation struct {
+	Start Token
+	Parts []Expr
+	End   Token
+}
+
+func (e *ExprInterpolation) Accept(visitor ExprVisitor) error {
+	return visitor.VisitInterpolation(e)
+}
+
+func (e *ExprInterpolation) Type() DataType {
+	return DTString
+}
+
+func (e *ExprInterpolation) Position() (start, end Position) {
+	return e.Start.Pos, e.End.EndPos
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 )
 
@@ -13,12 +14,17 @@ type parser struct {
 	tokens  []Token
 	current int
 	errors  []error
+	// exports contains the exported names of the module which is currently parsed. It is nil outside of modules.
+	exports []Token
+	// modules contains the files of the imported modules which were already parsed.
+	modules map[string]bool
 }
 
 func Parse(tokens []Token) ([]Stmt, []error) {
 	parser := &parser{
-		tokens: tokens,
-		errors: make([]error, 0),
+		tokens:  tokens,
+		errors:  make([]error, 0),
+		modules: make(map[string]bool),
 	}
 	return parser.parse()
 }
@@ -32,6 +38,7 @@ func (p *parser) parse() ([]Stmt, []error) {
 }
 
 func (p *parser) topLevel() Stmt {
+	p.contextualKeyword()
 	var err error
 	var stmt Stmt
 	switch p.peek().Type {
@@ -42,9 +49,25 @@ func (p *parser) topLevel() Stmt {
 	case TkFunc:
 		stmt, err = p.funcDecl()
 	case TkAt:
-		stmt, err = p.event()
+		if p.isWarpAttribute() {
+			stmt, err = p.warpFuncDecl()
+		} else {
+			stmt, err = p.event()
+		}
 	case TkEvent:
 		stmt, err = p.eventDecl()
+	case TkTypeDef:
+		stmt, err = p.typeDecl()
+	case TkEnum:
+		stmt, err = p.enumDecl()
+	case TkImport:
+		stmt, err = p.importStmt()
+	case TkExport:
+		stmt, err = p.exportDecl()
+	case TkModuleEnd:
+		// the module end of an import statement with errors
+		p.current++
+		return nil
 	default:
 		err = p.newError("Expected event or declaration.")
 	}
@@ -55,6 +78,149 @@ func (p *parser) topLevel() Stmt {
 	return stmt
 }
 
+// contextualKeyword turns the identifier 'type' or 'enum' into a keyword if it starts a declaration.
+// They are not reserved words, so programs can still use them as names.
+func (p *parser) contextualKeyword() {
+	if p.peek().Type != TkIdentifier || p.peekNext().Type != TkIdentifier {
+		return
+	}
+	switch p.peek().Lexeme {
+	case "type":
+		p.tokens[p.current].Type = TkTypeDef
+	case "enum":
+		p.tokens[p.current].Type = TkEnum
+	}
+}
+
+func (p *parser) importStmt() (Stmt, error) {
+	if !p.match(TkImport) {
+		return nil, p.newError("Expected 'import' keyword.")
+	}
+	keyword := p.previous()
+
+	stmt, err := p.importHeader(keyword)
+	if err != nil {
+		// the tokens of the module are skipped instead of synchronizing
+		p.errors = append(p.errors, err)
+		p.skipModule()
+		return nil, nil
+	}
+
+	// all imports of a file refer to the first one
+	if p.modules[stmt.File] {
+		p.skipModule()
+		return stmt, nil
+	}
+	p.modules[stmt.File] = true
+
+	exports := p.exports
+	p.exports = make([]Token, 0)
+	stmt.Body = make([]Stmt, 0)
+	for p.peek().Type != TkModuleEnd && p.peek().Type != TkEOF {
+		stmt.Body = append(stmt.Body, p.topLevel())
+	}
+	stmt.Exports = p.exports
+	p.exports = exports
+
+	if !p.match(TkModuleEnd) {
+		return nil, p.newErrorAt("Expected end of module.", keyword)
+	}
+	return stmt, nil
+}
+
+// importHeader parses the path and the name of an import statement up to and including the line break.
+// The name defaults to the file name of the module.
+func (p *parser) importHeader(keyword Token) (*StmtImport, error) {
+	if !p.match(TkLiteral) || p.previous().DataType != DTString {
+		return nil, p.newError("Expected module path after 'import'.")
+	}
+	path := p.previous()
+
+	var alias Token
+	if p.peek().Type == TkIdentifier && p.peek().Lexeme == "as" {
+		p.current++
+		if !p.match(TkIdentifier) {
+			return nil, p.newError("Expected module name after 'as'.")
+		}
+		alias = p.previous()
+		if strings.Contains(alias.Lexeme, ".") {
+			return nil, p.newErrorAt("Module names cannot contain a dot.", alias)
+		}
+	} else {
+		alias = path
+		alias.Type = TkIdentifier
+		alias.Lexeme = strings.TrimSuffix(filepath.Base(path.Literal.(string)), ".mb")
+		if !isIdentifier(alias.Lexeme) {
+			return nil, p.newError("Expected 'as' after module path.")
+		}
+	}
+
+	if !p.match(TkNewLine) {
+		return nil, p.newError("Expected '\\n' after import statement.")
+	}
+
+	file, _ := keyword.Literal.(string)
+	return &StmtImport{
+		Keyword: keyword,
+		Path:    path,
+		Alias:   alias,
+		File:    file,
+	}, nil
+}
+
+// skipModule skips the rest of an import statement including the tokens of the module.
+func (p *parser) skipModule() {
+	depth := 1
+	for p.peek().Type != TkEOF {
+		p.current++
+		switch p.previous().Type {
+		case TkImport:
+			depth++
+		case TkModuleEnd:
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// exportDecl parses a declaration marked with 'export' and adds its name to the exports of the current module.
+func (p *parser) exportDecl() (Stmt, error) {
+	if !p.match(TkExport) {
+		return nil, p.newError("Expected 'export' keyword.")
+	}
+	p.contextualKeyword()
+	switch p.peek().Type {
+	case TkVar, TkConst, TkFunc, TkEvent, TkTypeDef, TkEnum:
+	default:
+		if !p.isWarpAttribute() {
+			p.errors = append(p.errors, p.newError("Expected declaration after 'export'."))
+			return p.topLevel(), nil
+		}
+	}
+
+	stmt := p.topLevel()
+	if p.exports == nil {
+		return stmt, nil
+	}
+	switch s := stmt.(type) {
+	case *StmtVarDecl:
+		p.exports = append(p.exports, s.Name)
+	case *StmtConstDecl:
+		p.exports = append(p.exports, s.Name)
+	case *StmtFuncDecl:
+		p.exports = append(p.exports, s.Name)
+	case *StmtEventDecl:
+		p.exports = append(p.exports, s.Name)
+	case *StmtTypeDecl:
+		p.exports = append(p.exports, s.Name)
+	case *StmtEnumDecl:
+		p.exports = append(p.exports, s.Name)
+	}
+	return stmt, nil
+}
+
 func (p *parser) varDecl() (Stmt, error) {
 	if !p.match(TkVar) {
 		return nil, p.newError("Expected 'var' keyword.")
@@ -69,20 +235,36 @@ func (p *parser) varDecl() (Stmt, error) {
 	}
 
 	var dataType DataType
+	var typeToken Token
+	var dimensions []Expr
 	if p.match(TkColon) {
-		if !p.match(TkType) {
-			return nil, p.newError("Expected type after ':'.")
-		}
-		var ok bool
-		dataType, ok = types[p.previous().Lexeme]
-		if !ok {
-			if dataType, ok = types[strings.TrimSuffix(p.previous().Lexeme, "[]")]; !ok {
-				return nil, p.newError("Unknown data type.")
+		if p.match(TkIdentifier) {
+			typeToken = p.previous()
+			dataType = DataType(typeToken.Lexeme)
+			if p.match(TkOpenBracket) {
+				if !p.match(TkCloseBracket) {
+					return nil, p.newError("Expected ']' after '['.")
+				}
+				typeToken.EndPos = p.previous().EndPos
+				dataType += "[]"
 			}
-			dataType += "[]"
-		}
-		if dataType == DTBool {
-			return nil, p.newErrorAt("Boolean variables are not supported.", p.previous())
+		} else if p.match(TkType) {
+			typeToken = p.previous()
+			var ok bool
+			dataType, ok = types[p.previous().Lexeme]
+			if !ok {
+				if dataType, ok = types[strings.TrimSuffix(p.previous().Lexeme, "[]")]; !ok {
+					return nil, p.newError("Unknown data type.")
+				}
+				dataType += "[]"
+			}
+			var err error
+			dimensions, err = p.dimensions(&typeToken, &dataType)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, p.newError("Expected type after ':'.")
 		}
 	}
 
@@ -103,12 +285,48 @@ func (p *parser) varDecl() (Stmt, error) {
 
 	return &StmtVarDecl{
 		Name:        name,
+		Type:        typeToken,
 		DataType:    dataType,
+		Dimensions:  dimensions,
 		AssignToken: assignToken,
 		Value:       value,
 	}, nil
 }
 
+// dimensions parses the sizes of a 2D list after its element type, e.g. '[3][4]' in 'number[3][4]' or the second '[]' in 'number[][]'.
+// It extends typeToken and sets dataType to the type of the underlying list.
+func (p *parser) dimensions(typeToken *Token, dataType *DataType) ([]Expr, error) {
+	if p.peek().Type != TkOpenBracket || *dataType == DTImage {
+		return nil, nil
+	}
+	if strings.HasSuffix(string(*dataType), "[]") {
+		p.match(TkOpenBracket)
+		if !p.match(TkCloseBracket) {
+			return nil, p.newError("Expected ']' after '['.")
+		}
+		typeToken.EndPos = p.previous().EndPos
+		return []Expr{nil, nil}, nil
+	}
+
+	dimensions := make([]Expr, 2)
+	for i := range dimensions {
+		if !p.match(TkOpenBracket) {
+			return nil, p.newError("Expected '[' after the number of rows.")
+		}
+		size, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if !p.match(TkCloseBracket) {
+			return nil, p.newError("Expected ']' after list size.")
+		}
+		dimensions[i] = size
+	}
+	typeToken.EndPos = p.previous().EndPos
+	*dataType += "[]"
+	return dimensions, nil
+}
+
 func (p *parser) constDecl() (Stmt, error) {
 	if !p.match(TkConst) {
 		return nil, p.newError("Expected 'const' keyword.")
@@ -127,9 +345,13 @@ func (p *parser) constDecl() (Stmt, error) {
 			return nil, p.newError("Expected type after ':'.")
 		}
 
-		if _, ok := types[p.previous().Lexeme]; !ok {
+		if _, ok := types[strings.TrimSuffix(p.previous().Lexeme, "[]")]; !ok {
 			return nil, p.newError("Unknown data type.")
 		}
+		// the dimensions of constant 2D lists are inferred from the value
+		if strings.HasSuffix(p.previous().Lexeme, "[]") && p.match(TkOpenBracket) && !p.match(TkCloseBracket) {
+			return nil, p.newError("Expected ']' after '['.")
+		}
 	}
 
 	if !p.match(TkAssign) {
@@ -153,23 +375,8 @@ func (p *parser) constDecl() (Stmt, error) {
 	}, nil
 }
 
-func (p *parser) funcDecl() (Stmt, error) {
-	if !p.match(TkFunc) {
-		return nil, p.newError("Expected 'func' keyword.")
-	}
-
-	if !p.match(TkIdentifier) {
-		return nil, p.newError("Expected function name.")
-	}
-	name := p.previous()
-	if strings.Contains(name.Lexeme, ".") {
-		return nil, p.newErrorAt("Function names cannot contain a dot.", name)
-	}
-
-	if !p.match(TkOpenParen) {
-		return nil, p.newError("Expected '(' after function name.")
-	}
-
+// params parses a parameter list after the opening parenthesis up to and including the closing parenthesis.
+func (p *parser) params() ([]FuncParam, error) {
 	parameters := make([]FuncParam, 0)
 	for p.peek().Type != TkCloseParen && p.peek().Type != TkEOF {
 		if !p.match(TkIdentifier) {
@@ -195,12 +402,64 @@ func (p *parser) funcDecl() (Stmt, error) {
 	if !p.match(TkCloseParen) {
 		return nil, p.newError("Expected ')' after parameter list.")
 	}
+	return parameters, nil
+}
+
+// isWarpAttribute reports whether the next tokens are '@warp func'.
+func (p *parser) isWarpAttribute() bool {
+	return p.peek().Type == TkAt && p.peekNext().Type == TkIdentifier && p.peekNext().Lexeme == "warp" &&
+		p.current+2 < len(p.tokens) && p.tokens[p.current+2].Type == TkFunc
+}
+
+func (p *parser) warpFuncDecl() (Stmt, error) {
+	if !p.isWarpAttribute() {
+		return nil, p.newError("Expected '@warp'.")
+	}
+	p.current += 2
+
+	stmt, err := p.funcDecl()
+	if err != nil {
+		return nil, err
+	}
+	stmt.(*StmtFuncDecl).Warp = true
+	return stmt, nil
+}
+
+func (p *parser) funcDecl() (Stmt, error) {
+	if !p.match(TkFunc) {
+		return nil, p.newError("Expected 'func' keyword.")
+	}
+
+	if !p.match(TkIdentifier) {
+		return nil, p.newError("Expected function name.")
+	}
+	name := p.previous()
+	if strings.Contains(name.Lexeme, ".") {
+		return nil, p.newErrorAt("Function names cannot contain a dot.", name)
+	}
+
+	if !p.match(TkOpenParen) {
+		return nil, p.newError("Expected '(' after function name.")
+	}
+
+	parameters, err := p.params()
+	if err != nil {
+		return nil, err
+	}
 	closeParen := p.previous()
 
 	if !p.match(TkColon) {
 		return nil, p.newError("Expected ':' after function declaration.")
 	}
 
+	var returnType Token
+	if p.match(TkType) {
+		returnType = p.previous()
+		if !p.match(TkColon) {
+			return nil, p.newError("Expected ':' after return type.")
+		}
+	}
+
 	if !p.match(TkNewLine) {
 		return nil, p.newError("Expected '\\n' after ':'.")
 	}
@@ -212,6 +471,7 @@ func (p *parser) funcDecl() (Stmt, error) {
 	return &StmtFuncDecl{
 		Name:       name,
 		CloseParen: closeParen,
+		ReturnType: returnType,
 		Body:       body,
 		Params:     parameters,
 		StartLine:  start,
@@ -232,7 +492,8 @@ func (p *parser) event() (Stmt, error) {
 
 	var parameter Expr
 	var err error
-	if p.peek().Type != TkColon && p.peek().Type != TkNewLine {
+	params, isParams := p.eventParams()
+	if !isParams && p.peek().Type != TkColon && p.peek().Type != TkNewLine {
 		parameter, err = p.expression()
 		if err != nil {
 			p.errors = append(p.errors, err)
@@ -256,10 +517,32 @@ func (p *parser) event() (Stmt, error) {
 		At:        at,
 		Name:      name,
 		Parameter: parameter,
+		Params:    params,
 		Body:      body,
 	}, nil
 }
 
+// eventParams parses the parameter names of a custom event handler: '(' (IDENTIFIER (',' IDENTIFIER)*)? ')' ':'
+// Nothing is consumed if the tokens don't match.
+func (p *parser) eventParams() ([]Token, bool) {
+	start := p.current
+	if !p.match(TkOpenParen) {
+		return nil, false
+	}
+	names := make([]Token, 0)
+	for p.match(TkIdentifier) {
+		names = append(names, p.previous())
+		if !p.match(TkComma) {
+			break
+		}
+	}
+	if !p.match(TkCloseParen) || p.peek().Type != TkColon {
+		p.current = start
+		return nil, false
+	}
+	return names, true
+}
+
 func (p *parser) eventDecl() (Stmt, error) {
 	if !p.match(TkEvent) {
 		return nil, p.newError("Expected event keyword.")
@@ -272,12 +555,133 @@ func (p *parser) eventDecl() (Stmt, error) {
 	if strings.Contains(name.Lexeme, ".") {
 		return nil, p.newErrorAt("Event names cannot contain a dot.", name)
 	}
+	var parameters []FuncParam
+	if p.match(TkOpenParen) {
+		var err error
+		parameters, err = p.params()
+		if err != nil {
+			return nil, err
+		}
+	}
 	if !p.match(TkNewLine) {
 		return nil, p.newError("Expected '\\n' after event declaration.")
 	}
 	return &StmtEventDecl{
 		Keyword: keyword,
 		Name:    name,
+		Params:  parameters,
+	}, nil
+}
+
+func (p *parser) typeDecl() (Stmt, error) {
+	if !p.match(TkTypeDef) {
+		return nil, p.newError("Expected 'type' keyword.")
+	}
+	keyword := p.previous()
+	if !p.match(TkIdentifier) {
+		return nil, p.newError("Expected type name.")
+	}
+	name := p.previous()
+	if strings.Contains(name.Lexeme, ".") {
+		return nil, p.newErrorAt("Type names cannot contain a dot.", name)
+	}
+	if !p.match(TkOpenBrace) {
+		return nil, p.newError("Expected '{' after type name.")
+	}
+
+	// fields are separated by commas and/or line breaks
+	fields := make([]FuncParam, 0)
+	for {
+		for p.match(TkNewLine) {
+		}
+		if p.peek().Type == TkCloseBrace || p.peek().Type == TkEOF {
+			break
+		}
+		if !p.match(TkIdentifier) {
+			return nil, p.newError("Expected field name.")
+		}
+		fieldName := p.previous()
+		if strings.Contains(fieldName.Lexeme, ".") {
+			return nil, p.newErrorAt("Field names cannot contain a dot.", fieldName)
+		}
+		if !p.match(TkColon) {
+			return nil, p.newError("Expected ':' after field name.")
+		}
+		if !p.match(TkType) {
+			return nil, p.newError("Expected type after ':'.")
+		}
+		fields = append(fields, FuncParam{
+			Name: fieldName,
+			Type: p.previous(),
+		})
+		if !p.match(TkComma) && p.peek().Type != TkNewLine {
+			break
+		}
+	}
+
+	if !p.match(TkCloseBrace) {
+		return nil, p.newError("Expected '}' after field list.")
+	}
+	closeBrace := p.previous()
+	if !p.match(TkNewLine) {
+		return nil, p.newError("Expected '\\n' after type declaration.")
+	}
+	return &StmtTypeDecl{
+		Keyword:    keyword,
+		Name:       name,
+		Fields:     fields,
+		CloseBrace: closeBrace,
+	}, nil
+}
+
+func (p *parser) enumDecl() (Stmt, error) {
+	if !p.match(TkEnum) {
+		return nil, p.newError("Expected 'enum' keyword.")
+	}
+	keyword := p.previous()
+	if !p.match(TkIdentifier) {
+		return nil, p.newError("Expected enum name.")
+	}
+	name := p.previous()
+	if strings.Contains(name.Lexeme, ".") {
+		return nil, p.newErrorAt("Enum names cannot contain a dot.", name)
+	}
+	if !p.match(TkOpenBrace) {
+		return nil, p.newError("Expected '{' after enum name.")
+	}
+
+	// values are separated by commas and/or line breaks
+	values := make([]Token, 0)
+	for {
+		for p.match(TkNewLine) {
+		}
+		if p.peek().Type == TkCloseBrace || p.peek().Type == TkEOF {
+			break
+		}
+		if !p.match(TkIdentifier) {
+			return nil, p.newError("Expected enum value.")
+		}
+		if strings.Contains(p.previous().Lexeme, ".") {
+			return nil, p.newErrorAt("Enum values cannot contain a dot.", p.previous())
+		}
+		values = append(values, p.previous())
+		if !p.match(TkComma) && p.peek().Type != TkNewLine {
+			break
+		}
+	}
+
+	if !p.match(TkCloseBrace) {
+		return nil, p.newError("Expected '}' after enum values.")
+	}
+	closeBrace := p.previous()
+	if !p.match(TkNewLine) {
+		return nil, p.newError("Expected '\\n' after enum declaration.")
+	}
+	return &StmtEnumDecl{
+		Keyword:    keyword,
+		Name:       name,
+		Values:     values,
+		CloseBrace: closeBrace,
 	}, nil
 }
 
@@ -303,6 +707,18 @@ func (p *parser) statement() (Stmt, error) {
 		return p.whileLoop()
 	case TkFor:
 		return p.forLoop()
+	case TkReturn:
+		return p.returnStmt()
+	case TkVar:
+		return p.varDecl()
+	case TkBreak, TkContinue:
+		return p.loopControl()
+	case TkMatch:
+		return p.matchStmt()
+	case TkAwait:
+		return p.awaitCall()
+	case TkImport:
+		return nil, p.newError("Modules can only be imported at the top level.")
 	}
 
 	if p.peekNext().Type == TkOpenParen {
@@ -314,6 +730,23 @@ func (p *parser) statement() (Stmt, error) {
 	return nil, p.newError("Expected statement.")
 }
 
+func (p *parser) awaitCall() (Stmt, error) {
+	if !p.match(TkAwait) {
+		return nil, p.newError("Expected 'await' keyword.")
+	}
+	keyword := p.previous()
+
+	if p.peek().Type != TkIdentifier || p.peekNext().Type != TkOpenParen {
+		return nil, p.newError("Expected event call after 'await'.")
+	}
+	stmt, err := p.funcCall()
+	if err != nil {
+		return nil, err
+	}
+	stmt.(*StmtCall).Await = keyword
+	return stmt, nil
+}
+
 func (p *parser) funcCall() (Stmt, error) {
 	if !p.match(TkIdentifier) {
 		return nil, p.newError("Expected identifier.")
@@ -518,6 +951,79 @@ func (p *parser) ifStmt() (Stmt, error) {
 	return stmt, nil
 }
 
+func (p *parser) matchStmt() (Stmt, error) {
+	if !p.match(TkMatch) {
+		return nil, p.newError("Expected 'match' keyword.")
+	}
+	keyword := p.previous()
+
+	subject, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.match(TkColon) {
+		return nil, p.newError("Expected ':' after match value.")
+	}
+	if !p.match(TkNewLine) {
+		return nil, p.newError("Expected '\\n' after ':'.")
+	}
+
+	stmt := &StmtMatch{
+		Keyword: keyword,
+		Subject: subject,
+		Cases:   make([]MatchCase, 0),
+	}
+
+	for p.peek().Type == TkCase && p.peek().Indent > keyword.Indent {
+		p.current++
+		caseKeyword := p.previous()
+
+		values := make([]Expr, 0, 1)
+		for {
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if !p.match(TkComma) {
+				break
+			}
+		}
+
+		if !p.match(TkColon) {
+			return nil, p.newError("Expected ':' after case values.")
+		}
+		if !p.match(TkNewLine) {
+			return nil, p.newError("Expected '\\n' after ':'.")
+		}
+
+		stmt.Cases = append(stmt.Cases, MatchCase{
+			Keyword: caseKeyword,
+			Values:  values,
+			Body:    p.statements(caseKeyword.Indent + 1),
+		})
+	}
+
+	if p.peek().Type == TkElse && p.peek().Indent > keyword.Indent {
+		p.current++
+		elseKeyword := p.previous()
+		if !p.match(TkColon) {
+			return nil, p.newError("Expected ':' after 'else'.")
+		}
+		if !p.match(TkNewLine) {
+			return nil, p.newError("Expected '\\n' after ':'.")
+		}
+		stmt.ElseBody = p.statements(elseKeyword.Indent + 1)
+	}
+
+	if len(stmt.Cases) == 0 {
+		return nil, p.newErrorAt("Expected at least one case.", keyword)
+	}
+
+	return stmt, nil
+}
+
 func (p *parser) whileLoop() (Stmt, error) {
 	if !p.match(TkWhile) {
 		return nil, p.newError("Expected 'while' keyword.")
@@ -576,6 +1082,15 @@ func (p *parser) forLoop() (Stmt, error) {
 	}
 	keyword := p.previous()
 
+	var variable Token
+	if p.peek().Type == TkIdentifier && p.peekNext().Type == TkIn {
+		variable = p.peek()
+		p.current += 2
+		if strings.Contains(variable.Lexeme, ".") {
+			return nil, p.newErrorAt("Variable names cannot contain a dot.", variable)
+		}
+	}
+
 	var condition Expr
 	var err error
 	if p.peek().Type != TkColon {
@@ -583,7 +1098,25 @@ func (p *parser) forLoop() (Stmt, error) {
 		if err != nil {
 			p.errors = append(p.errors, err)
 			p.synchronize()
+		} else if variable.Type == TkIdentifier && p.match(TkDotDot) {
+			operator := p.previous()
+			var end Expr
+			end, err = p.expression()
+			if err != nil {
+				p.errors = append(p.errors, err)
+				p.synchronize()
+			} else {
+				condition = &ExprRange{
+					Start:    condition,
+					Operator: operator,
+					End:      end,
+				}
+			}
 		}
+	} else if variable.Type == TkIdentifier {
+		err = p.newError("Expected range or list after 'in'.")
+		p.errors = append(p.errors, err)
+		p.synchronize()
 	}
 
 	if err == nil {
@@ -600,13 +1133,86 @@ func (p *parser) forLoop() (Stmt, error) {
 
 	return &StmtLoop{
 		Keyword:   keyword,
+		Variable:  variable,
 		Condition: condition,
 		Body:      body,
 	}, nil
 }
 
+func (p *parser) loopControl() (Stmt, error) {
+	if !p.match(TkBreak, TkContinue) {
+		return nil, p.newError("Expected 'break' or 'continue' keyword.")
+	}
+	keyword := p.previous()
+
+	if !p.match(TkNewLine) {
+		return nil, p.newError("Expected '\\n' after statement.")
+	}
+
+	return &StmtLoopControl{
+		Keyword: keyword,
+	}, nil
+}
+
+func (p *parser) returnStmt() (Stmt, error) {
+	if !p.match(TkReturn) {
+		return nil, p.newError("Expected 'return' keyword.")
+	}
+	keyword := p.previous()
+
+	var value Expr
+	if p.peek().Type != TkNewLine {
+		var err error
+		value, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.match(TkNewLine) {
+		return nil, p.newError("Expected '\\n' after statement.")
+	}
+
+	return &StmtReturn{
+		Keyword: keyword,
+		Value:   value,
+	}, nil
+}
+
 func (p *parser) expression() (Expr, error) {
-	return p.or()
+	return p.ternary()
+}
+
+func (p *parser) ternary() (Expr, error) {
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.match(TkQuestionMark) {
+		questionMark := p.previous()
+		trueValue, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		if !p.match(TkColon) {
+			return nil, p.newError("Expected ':' after value.")
+		}
+		colon := p.previous()
+		falseValue, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		expr = &ExprTernary{
+			Condition:    expr,
+			QuestionMark: questionMark,
+			TrueValue:    trueValue,
+			Colon:        colon,
+			FalseValue:   falseValue,
+		}
+	}
+
+	return expr, nil
 }
 
 func (p *parser) or() (Expr, error) {
@@ -875,7 +1481,65 @@ func (p *parser) unary() (Expr, error) {
 		}
 	}
 
-	return p.primary()
+	return p.power()
+}
+
+// power parses the right associative '**' operator, which binds tighter than a unary operator on its left: -2 ** 2 == -4
+func (p *parser) power() (Expr, error) {
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.match(TkPower) {
+		operator := p.previous()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		expr = &ExprBinary{
+			Operator: operator,
+			Left:     expr,
+			Right:    right,
+		}
+	}
+
+	return expr, nil
+}
+
+func (p *parser) interpolation() (Expr, error) {
+	expr := &ExprInterpolation{
+		Start: p.previous(),
+		Parts: make([]Expr, 0, 3),
+	}
+	fragment := func(token Token) {
+		if token.Literal.(string) == "" {
+			return
+		}
+		token.Type = TkLiteral
+		expr.Parts = append(expr.Parts, &ExprLiteral{
+			Token: token,
+		})
+	}
+
+	fragment(expr.Start)
+	for {
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		expr.Parts = append(expr.Parts, value)
+
+		if p.match(TkInterpolationEnd) {
+			expr.End = p.previous()
+			fragment(expr.End)
+			return expr, nil
+		}
+		if !p.match(TkInterpolationMiddle) {
+			return nil, p.newError("Expected '}' after expression.")
+		}
+		fragment(p.previous())
+	}
 }
 
 func (p *parser) primary() (Expr, error) {
@@ -963,6 +1627,10 @@ func (p *parser) primary() (Expr, error) {
 		}, nil
 	}
 
+	if p.match(TkInterpolationStart) {
+		return p.interpolation()
+	}
+
 	if p.match(TkOpenParen) {
 		openParen := p.previous()
 		expr, err := p.expression()
@@ -1012,7 +1680,7 @@ func (p *parser) peekNext() Token {
 }
 
 func (p *parser) synchronize(tokens ...TokenType) bool {
-	if p.peek().Type == TkEOF {
+	if p.peek().Type == TkEOF || p.peek().Type == TkModuleEnd {
 		return false
 	}
 	p.current++
@@ -1024,6 +1692,8 @@ func (p *parser) synchronize(tokens ...TokenType) bool {
 		case TkNewLine:
 			p.current++
 			return false
+		case TkModuleEnd:
+			return false
 		}
 		for _, t := range tokens {
 			if p.peek().Type == t {
@@ -1038,9 +1708,13 @@ func (p *parser) synchronize(tokens ...TokenType) bool {
 type ParseError struct {
 	Token   Token
 	Message string
+	Warning bool
 }
 
 func (p ParseError) Error() string {
+	if p.Warning {
+		return "WARNING: " + p.Message
+	}
 	return "ERROR: " + p.Message
 }
 
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,10 +10,14 @@ import (
 	"strings"
 
 	"golang.org/x/exp/slices"
+
+	"github.com/juho05/embe/library"
 )
 
 type Define struct {
-	Name    Token
+	Name Token
+	// Params contains the parameters of function-like macros. It is nil for object-like macros.
+	Params  []Token
 	Start   Position
 	End     Position
 	Content []Token
@@ -20,14 +25,30 @@ type Define struct {
 
 type Defines struct {
 	defines map[string][]Define
+	// once contains the files marked with '#pragma once'. They are not included again.
+	once map[string]bool
 }
 
 func NewDefines() *Defines {
 	return &Defines{
 		defines: make(map[string][]Define),
+		once:    make(map[string]bool),
 	}
 }
 
+// LibraryPath is the directory of the bundled library files which can be included with '#include <name>'.
+// It only exists inside the compiler.
+const LibraryPath = "<embe>"
+
+// Version is the value of the __VERSION__ macro.
+var Version = "dev"
+
+// Target is the value of the __TARGET__ macro.
+const Target = "mbot2"
+
+// BuiltinMacros contains the names of the macros which are always defined.
+var BuiltinMacros = []string{"__FILE__", "__LINE__", "__VERSION__", "__TARGET__"}
+
 func (d *Defines) GetDefines(at Position) []Define {
 	defines := make([]Define, 0, 10)
 	for _, defs := range d.defines {
@@ -51,13 +72,14 @@ func (d *Defines) GetDefine(name string, at Position) (Define, bool) {
 	return Define{}, false
 }
 
-func (d *Defines) addDefine(token Token, pos Position, content []Token) {
+func (d *Defines) addDefine(token Token, pos Position, params []Token, content []Token) {
 	d.undefine(token)
 	if _, ok := d.defines[token.Lexeme]; !ok {
 		d.defines[token.Lexeme] = make([]Define, 0, 1)
 	}
 	d.defines[token.Lexeme] = append(d.defines[token.Lexeme], Define{
 		Name:    token,
+		Params:  params,
 		Start:   pos,
 		Content: content,
 	})
@@ -74,11 +96,76 @@ func (d *Defines) copy() *Defines {
 		copy(ds, v)
 		defines[k] = ds
 	}
+	once := make(map[string]bool, len(d.once))
+	for k, v := range d.once {
+		once[k] = v
+	}
 	return &Defines{
 		defines: defines,
+		once:    once,
 	}
 }
 
+// Define defines a macro from a definition of the form 'NAME' or 'NAME=value' before any source code is preprocessed.
+// Macros without a value are defined as 1.
+func (d *Defines) Define(definition string) error {
+	name, value, ok := strings.Cut(definition, "=")
+	if !ok {
+		value = "1"
+	}
+
+	nameTokens, err := scanMacro(name)
+	if err != nil || len(nameTokens) != 1 || nameTokens[0].Type != TkIdentifier {
+		return fmt.Errorf("Invalid macro name '%s'.", name)
+	}
+	if slices.Contains(BuiltinMacros, name) {
+		return fmt.Errorf("Cannot redefine builtin macro '%s'.", name)
+	}
+
+	content, err := scanMacro(value)
+	if err != nil {
+		return fmt.Errorf("Invalid value of macro '%s': %s", name, err)
+	}
+	d.addDefine(nameTokens[0], nameTokens[0].Pos, nil, content)
+	return nil
+}
+
+// Undefine removes all definitions of the macro name.
+func (d *Defines) Undefine(name string) {
+	delete(d.defines, name)
+}
+
+// predefined returns a copy of the macros which were defined with Define.
+func (d *Defines) predefined() *Defines {
+	result := NewDefines()
+	if d == nil {
+		return result
+	}
+	for name, defs := range d.defines {
+		for _, def := range defs {
+			if def.Name.Pos.Path == "" {
+				result.defines[name] = []Define{def}
+				break
+			}
+		}
+	}
+	return result
+}
+
+func scanMacro(source string) ([]Token, error) {
+	tokens, _, errs := Scan(strings.NewReader(source), "")
+	if len(errs) > 0 {
+		if e, ok := errs[0].(ScanError); ok {
+			return nil, errors.New(e.Message)
+		}
+		return nil, errs[0]
+	}
+	for len(tokens) > 0 && (tokens[len(tokens)-1].Type == TkNewLine || tokens[len(tokens)-1].Type == TkEOF) {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return tokens, nil
+}
+
 func (d *Defines) undefine(token Token) {
 	if def, ok := d.defines[token.Lexeme]; ok {
 		lastDef := def[len(def)-1]
@@ -93,7 +180,15 @@ func (d *Defines) undefine(token Token) {
 }
 
 func (d *Define) String() string {
-	str := fmt.Sprintf("#define %s ", d.Name.Lexeme)
+	str := "#define " + d.Name.Lexeme
+	if d.Params != nil {
+		params := make([]string, len(d.Params))
+		for i, p := range d.Params {
+			params[i] = p.Lexeme
+		}
+		str += "(" + strings.Join(params, ", ") + ")"
+	}
+	str += " "
 	for _, t := range d.Content {
 		str += t.Lexeme
 	}
@@ -105,17 +200,31 @@ func (d *Define) IsInScope(at Position) bool {
 }
 
 type preprocessor struct {
-	tokens  []Token
-	defines *Defines
-	index   int
-	errors  []error
-	files   map[string][][]rune
-	path    string
-	stack   []string
-	open    func(name string) (io.ReadCloser, error)
+	tokens     []Token
+	defines    *Defines
+	index      int
+	errors     []error
+	warnings   []error
+	files      map[string][][]rune
+	path       string
+	stack      []string
+	open       func(name string) (io.ReadCloser, error)
+	conditions []condition
+	// includePaths contains the directories which are searched for included files.
+	includePaths []string
 }
 
-func Preprocess(tokens []Token, absPath string, open func(name string) (io.ReadCloser, error), stack []string, defines *Defines) ([]Token, map[string][][]rune, *Defines, []string, []error) {
+// condition is an #if, #ifdef or #ifndef directive whose #endif has not been reached yet.
+type condition struct {
+	directive Token
+	// active is true if the tokens of the current branch are kept.
+	active bool
+	// taken is true if one of the branches was already active or if the enclosing branch is inactive.
+	taken   bool
+	hadElse bool
+}
+
+func Preprocess(tokens []Token, absPath string, open func(name string) (io.ReadCloser, error), stack []string, defines *Defines, includePaths []string) ([]Token, map[string][][]rune, *Defines, []string, []error, []error) {
 	eof := tokens[len(tokens)-1]
 
 	if stack == nil {
@@ -133,7 +242,7 @@ func Preprocess(tokens []Token, absPath string, open func(name string) (io.ReadC
 			pos := d.Name.Pos
 			pos.Line = 0
 			pos.Path = absPath
-			defines.addDefine(d.Name, pos, d.Content)
+			defines.addDefine(d.Name, pos, d.Params, d.Content)
 		}
 	}
 
@@ -144,13 +253,15 @@ func Preprocess(tokens []Token, absPath string, open func(name string) (io.ReadC
 	}
 
 	p := &preprocessor{
-		tokens:  tokens,
-		defines: defines,
-		errors:  make([]error, 0),
-		files:   make(map[string][][]rune),
-		stack:   stack,
-		path:    absPath,
-		open:    open,
+		tokens:       tokens,
+		defines:      defines,
+		errors:       make([]error, 0),
+		warnings:     make([]error, 0),
+		files:        make(map[string][][]rune),
+		stack:        stack,
+		path:         absPath,
+		open:         open,
+		includePaths: includePaths,
 	}
 	p.preprocess()
 
@@ -159,22 +270,90 @@ func Preprocess(tokens []Token, absPath string, open func(name string) (io.ReadC
 		p.tokens = append(p.tokens, eof)
 	}
 
-	return p.tokens, p.files, p.defines, p.stack, p.errors
+	return p.tokens, p.files, p.defines, p.stack, p.errors, p.warnings
 }
 
 func (p *preprocessor) preprocess() {
 	for p.index < len(p.tokens) {
-		if p.tokens[p.index].Type == TkPreprocessor {
-			err := p.directive(p.tokens[p.index].Lexeme)
+		token := p.tokens[p.index]
+		if token.Type == TkPreprocessor {
+			if !p.active() && !isConditionalDirective(token.Lexeme) {
+				p.skipLine()
+				continue
+			}
+			err := p.directive(token.Lexeme)
 			if err != nil {
 				p.errors = append(p.errors, err)
 				return
 			}
+		} else if !p.active() && token.Type != TkEOF {
+			end := p.index
+			for end < len(p.tokens) && p.tokens[end].Type != TkPreprocessor && p.tokens[end].Type != TkEOF {
+				end++
+			}
+			p.tokens = slices.Delete(p.tokens, p.index, end)
+		} else if token.Type == TkImport && token.Literal == nil {
+			err := p.importModule()
+			if err != nil {
+				p.errors = append(p.errors, err)
+			}
 		} else {
 			p.index++
 		}
 	}
-	p.replace()
+	if len(p.conditions) > 0 {
+		p.errors = append(p.errors, p.newErrorAt("Missing #endif.", p.conditions[len(p.conditions)-1].directive))
+	}
+	p.tokens = p.expandSource(p.tokens)
+}
+
+// expandSource replaces all macros in tokens except in imported modules which were already expanded with their own macros.
+func (p *preprocessor) expandSource(tokens []Token) []Token {
+	result := make([]Token, 0, len(tokens))
+	start := 0
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case t.Type == TkImport && t.Literal != nil:
+			if depth == 0 {
+				result = append(result, p.expand(tokens[start:i+1], nil)...)
+				start = i + 1
+			}
+			depth++
+		case t.Type == TkModuleEnd:
+			depth--
+			if depth == 0 {
+				result = append(result, tokens[start:i+1]...)
+				start = i + 1
+			}
+		}
+	}
+	return append(result, p.expand(tokens[start:], nil)...)
+}
+
+func isConditionalDirective(directive string) bool {
+	switch directive {
+	case "#if", "#ifdef", "#ifndef", "#elif", "#else", "#endif":
+		return true
+	}
+	return false
+}
+
+// active reports whether the tokens at the current position are kept.
+func (p *preprocessor) active() bool {
+	return len(p.conditions) == 0 || p.conditions[len(p.conditions)-1].active
+}
+
+// skipLine removes the directive at the current position and the rest of its line.
+func (p *preprocessor) skipLine() {
+	end := p.index + 1
+	for end < len(p.tokens) && p.tokens[end].Type != TkNewLine && p.tokens[end].Type != TkEOF {
+		end++
+	}
+	if end < len(p.tokens) && p.tokens[end].Type == TkNewLine {
+		end++
+	}
+	p.tokens = slices.Delete(p.tokens, p.index, end)
 }
 
 func (p *preprocessor) directive(directive string) error {
@@ -183,7 +362,7 @@ func (p *preprocessor) directive(directive string) error {
 	case "#include":
 		if p.peek().Type == TkLiteral && p.peek().DataType == DTString {
 			p.index++
-			err := p.include(p.index-1, p.tokens[p.index].Literal.(string))
+			err := p.include(p.index-1, p.tokens[p.index].Literal.(string), strings.HasPrefix(p.tokens[p.index].Lexeme, "<"))
 			if err != nil {
 				return err
 			}
@@ -193,13 +372,35 @@ func (p *preprocessor) directive(directive string) error {
 	case "#define":
 		if p.peek().Type == TkIdentifier {
 			p.index++
-			nameIndex := p.index
+			name := p.tokens[p.index]
+			if slices.Contains(BuiltinMacros, name.Lexeme) {
+				p.errors = append(p.errors, p.newErrorAt("Cannot redefine builtin macros.", name))
+				p.skipExpression()
+				p.skipNewLine()
+				break
+			}
+			var params []Token
+			// a parenthesis directly after the name starts the parameter list of a function-like macro
+			if next := p.peek(); next.Type == TkOpenParen && next.Pos.Line == name.Pos.Line && next.Pos.Column == name.EndPos.Column+1 {
+				p.index++
+				var err error
+				params, err = p.macroParams()
+				if err != nil {
+					p.errors = append(p.errors, err)
+					for p.peek().Type != TkNewLine && p.peek().Type != TkEOF {
+						p.index++
+					}
+					p.index++
+					break
+				}
+			}
+			contentIndex := p.index
 			for p.peek().Type != TkNewLine && p.peek().Type != TkEOF {
 				p.index++
 			}
-			replace := make([]Token, p.index-nameIndex)
-			copy(replace, p.tokens[nameIndex+1:p.index+1])
-			p.defines.addDefine(p.tokens[nameIndex], p.tokens[nameIndex].Pos, replace)
+			replace := make([]Token, p.index-contentIndex)
+			copy(replace, p.tokens[contentIndex+1:p.index+1])
+			p.defines.addDefine(name, name.Pos, params, replace)
 			p.index++
 		} else {
 			p.errors = append(p.errors, p.newError("Expected name after #define."))
@@ -207,30 +408,93 @@ func (p *preprocessor) directive(directive string) error {
 	case "#undef":
 		if p.peek().Type == TkIdentifier {
 			p.index++
+			if slices.Contains(BuiltinMacros, p.tokens[p.index].Lexeme) {
+				p.errors = append(p.errors, p.newErrorAt("Cannot undefine builtin macros.", p.tokens[p.index]))
+			}
 			p.defines.undefine(p.tokens[p.index])
 			p.index++
 		} else {
 			p.errors = append(p.errors, p.newError("Expected name after #undef."))
 		}
 	case "#ifdef", "#ifndef":
+		directive := p.tokens[p.index]
 		if p.peek().Type == TkIdentifier {
 			p.index++
-			if _, ok := p.defines.GetDefine(p.tokens[p.index].Lexeme, p.tokens[p.index].Pos); (p.tokens[p.index-1].Lexeme == "#ifdef") != ok {
-				for (p.peek().Type != TkPreprocessor || p.peek().Lexeme != "#endif") && p.peek().Type != TkEOF {
-					p.index++
-				}
-				p.index++
-			}
-			if p.peek().Type == TkNewLine {
-				p.index++
-			}
+			p.pushCondition(directive, p.isDefined(p.tokens[p.index]) == (directive.Lexeme == "#ifdef"))
 		} else {
-			p.errors = append(p.errors, p.newError("Expected name after #ifdef."))
+			p.errors = append(p.errors, p.newError(fmt.Sprintf("Expected name after %s.", directive.Lexeme)))
+			p.pushCondition(directive, false)
 		}
+		p.skipNewLine()
+	case "#if":
+		directive := p.tokens[p.index]
+		if !p.active() {
+			p.pushCondition(directive, false)
+			p.skipExpression()
+		} else {
+			p.pushCondition(directive, p.evaluateCondition())
+		}
+		p.skipNewLine()
+	case "#elif":
+		directive := p.tokens[p.index]
+		if len(p.conditions) == 0 {
+			p.errors = append(p.errors, p.newErrorAt("#elif without #if.", directive))
+			p.skipExpression()
+		} else if c := &p.conditions[len(p.conditions)-1]; c.hadElse {
+			p.errors = append(p.errors, p.newErrorAt("#elif after #else.", directive))
+			p.skipExpression()
+		} else if c.taken {
+			c.active = false
+			p.skipExpression()
+		} else {
+			c.active = p.evaluateCondition()
+			c.taken = c.active
+		}
+		p.skipNewLine()
+	case "#else":
+		directive := p.tokens[p.index]
+		if len(p.conditions) == 0 {
+			p.errors = append(p.errors, p.newErrorAt("#else without #if.", directive))
+		} else if c := &p.conditions[len(p.conditions)-1]; c.hadElse {
+			p.errors = append(p.errors, p.newErrorAt("Duplicate #else.", directive))
+		} else {
+			c.hadElse = true
+			c.active = !c.taken
+			c.taken = true
+		}
+		p.skipNewLine()
 	case "#endif":
-		if p.peek().Type == TkNewLine && p.index > 0 && p.tokens[p.index-1].Type == TkNewLine {
+		if len(p.conditions) == 0 {
+			p.errors = append(p.errors, p.newErrorAt("#endif without #if.", p.tokens[p.index]))
+		} else {
+			p.conditions = p.conditions[:len(p.conditions)-1]
+		}
+		p.skipNewLine()
+	case "#error", "#warning":
+		directive := p.tokens[p.index]
+		if p.peek().Type == TkLiteral && p.peek().DataType == DTString {
 			p.index++
+			token := directive
+			token.EndPos = p.tokens[p.index].EndPos
+			if directive.Lexeme == "#error" {
+				p.errors = append(p.errors, p.newErrorAt(p.tokens[p.index].Literal.(string), token))
+			} else {
+				p.warnings = append(p.warnings, p.newWarningAt(p.tokens[p.index].Literal.(string), token))
+			}
+		} else {
+			p.errors = append(p.errors, p.newError(fmt.Sprintf("Expected message after %s.", directive.Lexeme)))
+		}
+		p.skipExpression()
+		p.skipNewLine()
+	case "#pragma":
+		if p.peek().Type == TkIdentifier && p.peek().Lexeme == "once" {
+			p.index++
+			p.defines.once[p.path] = true
+		} else {
+			p.errors = append(p.errors, p.newError("Unknown pragma."))
+			p.skipExpression()
 		}
+		p.skipNewLine()
 	default:
 		p.errors = append(p.errors, p.newErrorAt("Unknown preprocessor directive.", p.tokens[p.index]))
 	}
@@ -242,19 +506,21 @@ func (p *preprocessor) directive(directive string) error {
 	return nil
 }
 
-func (p *preprocessor) include(keywordIndex int, path string) error {
-	if filepath.Ext(path) != ".mb" {
-		path += ".mb"
+// include replaces the #include directive at keywordIndex with the preprocessed content of the file name.
+// Files included with '#include "name"' are searched relative to the current file and in the include paths.
+// Files included with '#include <name>' are searched in the include paths and in the bundled library.
+func (p *preprocessor) include(keywordIndex int, name string, bracket bool) error {
+	if filepath.Ext(name) != ".mb" {
+		name += ".mb"
 	}
 
-	path = filepath.Join(filepath.Dir(p.path), path)
-	if runtime.GOOS == "windows" {
-		path = strings.ToLower(path)
-	}
-
-	file, err := p.open(path)
+	path, file, err := p.findFile(name, bracket, true, p.tokens[keywordIndex+1])
 	if err != nil {
-		return p.newErrorAt(fmt.Sprintf("Unable to open file `%s`: %s", path, err), p.tokens[keywordIndex+1])
+		return err
+	}
+	if file == nil {
+		p.skipNewLine()
+		return nil
 	}
 	defer file.Close()
 	tokens, lines, errs := Scan(file, path)
@@ -264,21 +530,14 @@ func (p *preprocessor) include(keywordIndex int, path string) error {
 		return errs[len(errs)-1]
 	}
 
-	if slices.Contains(p.stack, path) {
-		files := make([]string, len(p.stack))
-		index := 0
-		for i, f := range p.stack {
-			files[i] = filepath.Base(f)
-			if f == path {
-				index = i
-			}
-		}
-		return p.newErrorAt(fmt.Sprintf("Include cycle detected: %s -> %s", strings.Join(files[index:], " -> "), filepath.Base(path)), p.tokens[keywordIndex+1])
+	if err := p.checkCycle(path, "Include", p.tokens[keywordIndex+1]); err != nil {
+		return err
 	}
 
 	p.stack = append(p.stack, path)
-	tokens, files, defines, stack, errs := Preprocess(tokens, path, p.open, p.stack, p.defines)
+	tokens, files, defines, stack, errs, warnings := Preprocess(tokens, path, p.open, p.stack, p.defines, p.includePaths)
 	p.stack = stack[:len(stack)-1]
+	p.warnings = append(p.warnings, warnings...)
 	for k, v := range files {
 		p.files[k] = v
 	}
@@ -286,9 +545,12 @@ func (p *preprocessor) include(keywordIndex int, path string) error {
 	for _, ds := range defines.defines {
 		for _, d := range ds {
 			pos := d.Name.Pos
-			p.defines.addDefine(d.Name, pos, d.Content)
+			p.defines.addDefine(d.Name, pos, d.Params, d.Content)
 		}
 	}
+	for f := range defines.once {
+		p.defines.once[f] = true
+	}
 	if len(errs) > 0 {
 		p.errors = append(p.errors, errs[:len(errs)-1]...)
 		return errs[len(errs)-1]
@@ -313,37 +575,335 @@ func (p *preprocessor) include(keywordIndex int, path string) error {
 	return nil
 }
 
-func (p *preprocessor) replace() {
-	for i := 0; i < len(p.tokens); i++ {
-		token := p.tokens[i]
-		if token.Type != TkIdentifier {
+// findFile opens the first existing file of the candidates of includeCandidates.
+// file is nil if the file was marked with '#pragma once' and skipOnce is true.
+func (p *preprocessor) findFile(name string, bracket, skipOnce bool, pathToken Token) (path string, file io.ReadCloser, err error) {
+	candidates := p.includeCandidates(name, bracket)
+	for _, path = range candidates {
+		if runtime.GOOS == "windows" {
+			path = strings.ToLower(path)
+		}
+		if skipOnce && p.defines.once[path] {
+			return path, nil, nil
+		}
+		file, err = p.openFile(path)
+		if err == nil {
+			return path, file, nil
+		}
+	}
+	if len(candidates) == 1 && !bracket {
+		return "", nil, p.newErrorAt(fmt.Sprintf("Unable to open file `%s`: %s", path, err), pathToken)
+	}
+	return "", nil, p.newErrorAt(fmt.Sprintf("Unable to find file `%s`.", name), pathToken)
+}
+
+// checkCycle returns an error if path is already being preprocessed.
+func (p *preprocessor) checkCycle(path, kind string, pathToken Token) error {
+	if !slices.Contains(p.stack, path) {
+		return nil
+	}
+	files := make([]string, len(p.stack))
+	index := 0
+	for i, f := range p.stack {
+		files[i] = filepath.Base(f)
+		if f == path {
+			index = i
+		}
+	}
+	return p.newErrorAt(fmt.Sprintf("%s cycle detected: %s -> %s", kind, strings.Join(files[index:], " -> "), filepath.Base(path)), pathToken)
+}
+
+// importModule inserts the preprocessed tokens of the module imported by the import statement at the current position
+// after the line of the statement, followed by a TkModuleEnd token.
+// Modules are preprocessed on their own: only the macros defined with Define are visible in them and their macros are not
+// visible in the importing file.
+func (p *preprocessor) importModule() error {
+	keywordIndex := p.index
+	p.index++
+	if p.tokens[p.index].Type != TkLiteral || p.tokens[p.index].DataType != DTString {
+		return p.newErrorAt("Expected module path after 'import'.", p.tokens[p.index])
+	}
+	pathToken := p.tokens[p.index]
+
+	name := pathToken.Literal.(string)
+	if filepath.Ext(name) != ".mb" {
+		name += ".mb"
+	}
+	path, file, err := p.findFile(name, strings.HasPrefix(pathToken.Lexeme, "<"), false, pathToken)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tokens, lines, errs := Scan(file, path)
+	p.files[path] = lines
+	if len(errs) > 0 {
+		p.errors = append(p.errors, errs[:len(errs)-1]...)
+		return errs[len(errs)-1]
+	}
+
+	if err := p.checkCycle(path, "Import", pathToken); err != nil {
+		return err
+	}
+
+	p.stack = append(p.stack, path)
+	tokens, files, _, stack, errs, warnings := Preprocess(tokens, path, p.open, p.stack, p.defines.predefined(), p.includePaths)
+	p.stack = stack[:len(stack)-1]
+	p.warnings = append(p.warnings, warnings...)
+	for k, v := range files {
+		p.files[k] = v
+	}
+	if len(errs) > 0 {
+		p.errors = append(p.errors, errs[:len(errs)-1]...)
+		return errs[len(errs)-1]
+	}
+
+	// the resolved path marks the import as done when the tokens are visited again after an #include
+	p.tokens[keywordIndex].Literal = path
+
+	end := tokens[len(tokens)-1]
+	end.Type = TkModuleEnd
+	end.Lexeme = ""
+	end.Indent = 0
+	tokens[len(tokens)-1] = end
+	for i := range tokens {
+		tokens[i].LineAfterInclude = p.tokens[keywordIndex].LineAfterInclude
+	}
+
+	for p.tokens[p.index].Type != TkNewLine && p.tokens[p.index].Type != TkEOF {
+		p.index++
+	}
+	if p.tokens[p.index].Type == TkNewLine {
+		p.index++
+	}
+	p.tokens = slices.Insert(p.tokens, p.index, tokens...)
+	p.index += len(tokens)
+	return nil
+}
+
+func (p *preprocessor) includeCandidates(name string, bracket bool) []string {
+	candidates := make([]string, 0, len(p.includePaths)+2)
+	if !bracket {
+		candidates = append(candidates, filepath.Join(filepath.Dir(p.path), name))
+	}
+	for _, dir := range append(slices.Clone(p.includePaths), filepath.SplitList(os.Getenv("EMBE_PATH"))...) {
+		if dir == "" {
 			continue
 		}
-		if d, ok := p.defines.GetDefine(token.Lexeme, token.Pos); ok {
-			if len(d.Content) == 0 {
-				size := 1
-				if i < len(p.tokens)-1 && p.tokens[i+1].Type == TkNewLine {
-					size = 2
-				}
-				slices.Delete(p.tokens, i, i+size)
-				i--
+		path, err := filepath.Abs(filepath.Join(dir, name))
+		if err == nil {
+			candidates = append(candidates, path)
+		}
+	}
+	if bracket {
+		candidates = append(candidates, filepath.Join(LibraryPath, name))
+	}
+	return candidates
+}
+
+func (p *preprocessor) openFile(path string) (io.ReadCloser, error) {
+	if IsLibraryPath(path) {
+		rel, _ := filepath.Rel(LibraryPath, path)
+		return library.Files.Open(filepath.ToSlash(rel))
+	}
+	return p.open(path)
+}
+
+// IsLibraryPath reports whether path belongs to a file of the bundled library.
+func IsLibraryPath(path string) bool {
+	rel, err := filepath.Rel(LibraryPath, path)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+func (p *preprocessor) pushCondition(directive Token, value bool) {
+	active := p.active()
+	p.conditions = append(p.conditions, condition{
+		directive: directive,
+		active:    active && value,
+		taken:     !active || value,
+	})
+}
+
+func (p *preprocessor) skipNewLine() {
+	if p.peek().Type == TkNewLine {
+		p.index++
+	}
+}
+
+// skipExpression skips the rest of the directive line without evaluating it.
+func (p *preprocessor) skipExpression() {
+	for p.peek().Type != TkNewLine && p.peek().Type != TkEOF {
+		p.index++
+	}
+}
+
+// macroParams parses the parameter list of a function-like macro after the opening parenthesis up to and including the closing parenthesis.
+func (p *preprocessor) macroParams() ([]Token, error) {
+	params := make([]Token, 0)
+	for p.peek().Type == TkIdentifier {
+		p.index++
+		param := p.tokens[p.index]
+		for _, other := range params {
+			if other.Lexeme == param.Lexeme {
+				return nil, p.newErrorAt("Duplicate macro parameter.", param)
+			}
+		}
+		params = append(params, param)
+		if p.peek().Type != TkComma {
+			break
+		}
+		p.index++
+	}
+	if p.peek().Type != TkCloseParen {
+		return nil, p.newError("Expected ')' after macro parameters.")
+	}
+	p.index++
+	return params, nil
+}
+
+// expand replaces all macros in tokens. The macros in hidden are currently being expanded and are not replaced again
+// to prevent infinite recursion.
+func (p *preprocessor) expand(tokens []Token, hidden []string) []Token {
+	result := make([]Token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.Type != TkIdentifier || slices.Contains(hidden, token.Lexeme) {
+			result = append(result, token)
+			continue
+		}
+		if builtin, ok := p.builtinMacro(token); ok {
+			result = append(result, builtin)
+			continue
+		}
+		d, ok := p.defines.GetDefine(token.Lexeme, token.Pos)
+		if !ok {
+			result = append(result, token)
+			continue
+		}
+
+		content := d.Content
+		end := token
+		if d.Params != nil {
+			args, closeIndex, ok := p.macroArgs(tokens, i+1)
+			if !ok {
+				// function-like macros are only replaced if they are called
+				result = append(result, token)
 				continue
 			}
-			newTokens := make([]Token, len(p.tokens)+len(d.Content)-1)
-			copy(newTokens, p.tokens[:i])
-			copy(newTokens[i:], d.Content)
-			if i < len(p.tokens)-1 {
-				copy(newTokens[i+len(d.Content):], p.tokens[i+1:])
+			i = closeIndex
+			end = tokens[closeIndex]
+			if len(args) == 1 && len(args[0]) == 0 && len(d.Params) == 0 {
+				args = nil
+			}
+			if len(args) != len(d.Params) {
+				p.errors = append(p.errors, p.newErrorAt(fmt.Sprintf("Wrong argument count. Expected %d.", len(d.Params)), token))
+				continue
+			}
+			content = d.substitute(args, func(arg []Token) []Token {
+				return p.expand(arg, hidden)
+			})
+		}
+
+		if len(content) == 0 {
+			if i < len(tokens)-1 && tokens[i+1].Type == TkNewLine {
+				i++
+			}
+			continue
+		}
+
+		replacement := make([]Token, len(content))
+		copy(replacement, content)
+		for j := range replacement {
+			replacement[j].Indent = token.Indent
+			replacement[j].Pos = token.Pos
+			replacement[j].EndPos = end.EndPos
+		}
+		result = append(result, p.expand(replacement, append(hidden[:len(hidden):len(hidden)], token.Lexeme))...)
+	}
+	return result
+}
+
+// macroArgs returns the arguments of the macro call starting with the opening parenthesis at start
+// and the index of the closing parenthesis. ok is false if there is no call.
+func (p *preprocessor) macroArgs(tokens []Token, start int) (args [][]Token, closeIndex int, ok bool) {
+	if start >= len(tokens) || tokens[start].Type != TkOpenParen {
+		return nil, 0, false
+	}
+	args = [][]Token{make([]Token, 0)}
+	depth := 0
+	for i := start + 1; i < len(tokens); i++ {
+		switch tokens[i].Type {
+		case TkOpenParen:
+			depth++
+		case TkCloseParen:
+			if depth == 0 {
+				return args, i, true
 			}
-			for j := i; j < len(newTokens) && j < i+len(d.Content); j++ {
-				newTokens[j].Indent = token.Indent
-				newTokens[j].Pos = token.Pos
-				newTokens[j].EndPos = token.EndPos
+			depth--
+		case TkComma:
+			if depth == 0 {
+				args = append(args, make([]Token, 0))
+				continue
 			}
-			p.tokens = newTokens
-			i--
+		case TkNewLine, TkEOF:
+			p.errors = append(p.errors, p.newErrorAt("Expected ')' after macro arguments.", tokens[i]))
+			return nil, 0, false
+		}
+		args[len(args)-1] = append(args[len(args)-1], tokens[i])
+	}
+	return nil, 0, false
+}
+
+// substitute returns the content of the macro with all parameters replaced by the expanded arguments.
+func (d *Define) substitute(args [][]Token, expand func(arg []Token) []Token) []Token {
+	expanded := make(map[string][]Token, len(args))
+	for i, param := range d.Params {
+		expanded[param.Lexeme] = expand(args[i])
+	}
+	content := make([]Token, 0, len(d.Content))
+	for _, t := range d.Content {
+		if arg, ok := expanded[t.Lexeme]; ok && t.Type == TkIdentifier {
+			content = append(content, arg...)
+		} else {
+			content = append(content, t)
 		}
 	}
+	return content
+}
+
+// isDefined reports whether name is a builtin macro or a macro which is defined at its position.
+func (p *preprocessor) isDefined(name Token) bool {
+	if slices.Contains(BuiltinMacros, name.Lexeme) {
+		return true
+	}
+	_, ok := p.defines.GetDefine(name.Lexeme, name.Pos)
+	return ok
+}
+
+// builtinMacro returns the literal token which replaces the builtin macro token.
+func (p *preprocessor) builtinMacro(token Token) (Token, bool) {
+	var value any
+	switch token.Lexeme {
+	case "__FILE__":
+		value = filepath.Base(token.Pos.Path)
+	case "__LINE__":
+		value = float64(token.Pos.Line + 1)
+	case "__VERSION__":
+		value = Version
+	case "__TARGET__":
+		value = Target
+	default:
+		return Token{}, false
+	}
+	token.Type = TkLiteral
+	token.Literal = value
+	if str, ok := value.(string); ok {
+		token.DataType = DTString
+		token.Lexeme = fmt.Sprintf("%q", str)
+	} else {
+		token.DataType = DTNumber
+		token.Lexeme = fmt.Sprint(value)
+	}
+	return token, true
 }
 
 func (p *preprocessor) peek() Token {
@@ -365,3 +925,11 @@ func (p *preprocessor) newErrorAt(message string, token Token) error {
 		Message: message,
 	}
 }
+
+func (p *preprocessor) newWarningAt(message string, token Token) error {
+	return ParseError{
+		Token:   token,
+		Message: message,
+		Warning: true,
+	}
+}
@@ -9,15 +9,24 @@ import (
 )
 
 var keywords = map[string]TokenType{
-	"if":    TkIf,
-	"elif":  TkElif,
-	"else":  TkElse,
-	"while": TkWhile,
-	"for":   TkFor,
-	"var":   TkVar,
-	"const": TkConst,
-	"func":  TkFunc,
-	"event": TkEvent,
+	"if":       TkIf,
+	"elif":     TkElif,
+	"else":     TkElse,
+	"while":    TkWhile,
+	"for":      TkFor,
+	"var":      TkVar,
+	"const":    TkConst,
+	"func":     TkFunc,
+	"event":    TkEvent,
+	"return":   TkReturn,
+	"break":    TkBreak,
+	"continue": TkContinue,
+	"in":       TkIn,
+	"match":    TkMatch,
+	"case":     TkCase,
+	"await":    TkAwait,
+	"import":   TkImport,
+	"export":   TkExport,
 }
 
 var types = map[string]DataType{
@@ -28,15 +37,16 @@ var types = map[string]DataType{
 }
 
 type scanner struct {
-	inputScanner      *bufio.Scanner
-	lines             [][]rune
-	line              int
-	tokenStartColumn  int
-	currentColumn     int
-	tokens            []Token
-	lineContainsToken bool
-	errors            []error
-	path              string
+	inputScanner       *bufio.Scanner
+	lines              [][]rune
+	line               int
+	tokenStartColumn   int
+	currentColumn      int
+	tokens             []Token
+	lineContainsToken  bool
+	interpolationDepth int
+	errors             []error
+	path               string
 }
 
 func Scan(source io.Reader, path string) ([]Token, [][]rune, []error) {
@@ -72,12 +82,20 @@ func (s *scanner) scan() {
 			s.addToken(TkOpenBracket)
 		case ']':
 			s.addToken(TkCloseBracket)
+		case '{':
+			s.addToken(TkOpenBrace)
 		case ':':
 			s.addToken(TkColon)
 		case '.':
-			s.addToken(TkDot)
+			if s.match('.') {
+				s.addToken(TkDotDot)
+			} else {
+				s.addToken(TkDot)
+			}
 		case ',':
 			s.addToken(TkComma)
+		case '?':
+			s.addToken(TkQuestionMark)
 		case '+':
 			if s.match('=') {
 				s.addToken(TkPlusAssign)
@@ -93,6 +111,8 @@ func (s *scanner) scan() {
 		case '*':
 			if s.match('=') {
 				s.addToken(TkMultiplyAssign)
+			} else if s.match('*') {
+				s.addToken(TkPower)
 			} else {
 				s.addToken(TkMultiply)
 			}
@@ -119,7 +139,9 @@ func (s *scanner) scan() {
 				s.addToken(TkAssign)
 			}
 		case '<':
-			if s.match('=') {
+			if s.afterInclude() {
+				s.includePath()
+			} else if s.match('=') {
 				s.addToken(TkLessEqual)
 			} else {
 				s.addToken(TkLess)
@@ -154,7 +176,14 @@ func (s *scanner) scan() {
 			s.addToken(TkAnd)
 
 		case '"':
-			s.string()
+			s.string(false)
+		case '}':
+			if s.interpolationDepth == 0 {
+				s.addToken(TkCloseBrace)
+				break
+			}
+			s.interpolationDepth--
+			s.string(true)
 
 		case ' ', '\t':
 
@@ -212,7 +241,7 @@ func (s *scanner) identifier() {
 
 	name := string(s.lines[s.line][s.tokenStartColumn : s.currentColumn+1])
 	if t, ok := types[name]; ok {
-		if name != "boolean" && s.peek() == '[' && s.peekNext() == ']' {
+		if s.peek() == '[' && s.peekNext() == ']' {
 			s.nextCharacter()
 			s.nextCharacter()
 			t += "[]"
@@ -286,10 +315,24 @@ func (s *scanner) comment() {
 	}
 }
 
-func (s *scanner) string() {
+// string scans a string literal. Strings containing expressions enclosed in '{' and '}' are split into
+// interpolation tokens with the tokens of the expressions in between. '{{' and '}}' insert a literal '{' and '}'.
+func (s *scanner) string(afterInterpolation bool) {
 	characters := make([]rune, 0)
 	for s.peek() != '"' && s.peek() != '\n' {
 		c, _ := s.nextCharacter()
+		if c == '}' {
+			s.match('}')
+		}
+		if c == '{' && !s.match('{') {
+			if afterInterpolation {
+				s.addTokenWithValue(TkInterpolationMiddle, DTString, string(characters))
+			} else {
+				s.addTokenWithValue(TkInterpolationStart, DTString, string(characters))
+			}
+			s.interpolationDepth++
+			return
+		}
 		characters = append(characters, c)
 	}
 	if !s.match('"') {
@@ -297,6 +340,32 @@ func (s *scanner) string() {
 		s.synchronize(false)
 		return
 	}
+	if afterInterpolation {
+		s.addTokenWithValue(TkInterpolationEnd, DTString, string(characters))
+	} else {
+		s.addTokenWithValue(TkLiteral, DTString, string(characters))
+	}
+}
+
+func (s *scanner) afterInclude() bool {
+	if len(s.tokens) == 0 {
+		return false
+	}
+	last := s.tokens[len(s.tokens)-1]
+	return (last.Type == TkPreprocessor && last.Lexeme == "#include" || last.Type == TkImport) && last.Pos.Line == s.line
+}
+
+// includePath scans the path of an '#include <path>' directive or an 'import <path>' statement into a string literal whose lexeme keeps the angle brackets.
+func (s *scanner) includePath() {
+	characters := make([]rune, 0)
+	for s.peek() != '>' && s.peek() != '\n' {
+		c, _ := s.nextCharacter()
+		characters = append(characters, c)
+	}
+	if !s.match('>') {
+		s.errors = append(s.errors, s.newError("Expected '>' after include path."))
+		return
+	}
 	s.addTokenWithValue(TkLiteral, DTString, string(characters))
 }
 
@@ -374,6 +443,7 @@ func (s *scanner) nextLine() (bool, error) {
 	s.line++
 	s.currentColumn = 0
 	s.tokenStartColumn = 0
+	s.interpolationDepth = 0
 
 	return true, nil
 }
@@ -470,6 +540,21 @@ func isAlphaNum(char rune) bool {
 	return isDigit(char, 10) || isAlpha(char)
 }
 
+// isIdentifier reports whether name can be scanned as a single identifier without dots.
+func isIdentifier(name string) bool {
+	if name == "" || isDigit(rune(name[0]), 10) {
+		return false
+	}
+	for _, c := range name {
+		if !isAlphaNum(c) {
+			return false
+		}
+	}
+	_, keyword := keywords[name]
+	_, dataType := types[name]
+	return !keyword && !dataType && name != "true" && name != "false"
+}
+
 type ScanError struct {
 	Pos     Position
 	Message string
@@ -5,11 +5,17 @@ type StmtVisitor interface {
 	VisitConstDecl(stmt *StmtConstDecl) error
 	VisitFuncDecl(stmt *StmtFuncDecl) error
 	VisitEventDecl(stmt *StmtEventDecl) error
+	VisitTypeDecl(stmt *StmtTypeDecl) error
+	VisitEnumDecl(stmt *StmtEnumDecl) error
 	VisitEvent(stmt *StmtEvent) error
 	VisitCall(stmt *StmtCall) error
 	VisitAssignment(stmt *StmtAssignment) error
 	VisitIf(stmt *StmtIf) error
 	VisitLoop(stmt *StmtLoop) error
+	VisitReturn(stmt *StmtReturn) error
+	VisitLoopControl(stmt *StmtLoopControl) error
+	VisitMatch(stmt *StmtMatch) error
+	VisitImport(stmt *StmtImport) error
 }
 
 type Stmt interface {
@@ -18,8 +24,12 @@ type Stmt interface {
 }
 
 type StmtVarDecl struct {
-	Name        Token
-	DataType    DataType
+	Name Token
+	// Type is the type annotation of the variable. Its lexeme is the name of the record type for record variables.
+	Type     Token
+	DataType DataType
+	// Dimensions contains the number of rows and columns of 2D lists. The elements are nil if the sizes were omitted ('number[][]').
+	Dimensions  []Expr
 	AssignToken Token
 	Value       Expr
 }
@@ -65,9 +75,12 @@ type StmtFuncDecl struct {
 	Name       Token
 	CloseParen Token
 	Params     []FuncParam
-	Body       []Stmt
-	StartLine  int
-	EndLine    int
+	ReturnType Token
+	// Warp is true if the function was declared with the @warp attribute.
+	Warp      bool
+	Body      []Stmt
+	StartLine int
+	EndLine   int
 }
 
 func (s *StmtFuncDecl) Accept(visitor StmtVisitor) error {
@@ -81,6 +94,7 @@ func (s *StmtFuncDecl) Position() (start, end Position) {
 type StmtEventDecl struct {
 	Keyword Token
 	Name    Token
+	Params  []FuncParam
 }
 
 func (s *StmtEventDecl) Accept(visitor StmtVisitor) error {
@@ -91,11 +105,43 @@ func (s *StmtEventDecl) Position() (start, end Position) {
 	return s.Keyword.Pos, s.Name.EndPos
 }
 
+type StmtTypeDecl struct {
+	Keyword    Token
+	Name       Token
+	Fields     []FuncParam
+	CloseBrace Token
+}
+
+func (s *StmtTypeDecl) Accept(visitor StmtVisitor) error {
+	return visitor.VisitTypeDecl(s)
+}
+
+func (s *StmtTypeDecl) Position() (start, end Position) {
+	return s.Keyword.Pos, s.CloseBrace.Pos
+}
+
+type StmtEnumDecl struct {
+	Keyword    Token
+	Name       Token
+	Values     []Token
+	CloseBrace Token
+}
+
+func (s *StmtEnumDecl) Accept(visitor StmtVisitor) error {
+	return visitor.VisitEnumDecl(s)
+}
+
+func (s *StmtEnumDecl) Position() (start, end Position) {
+	return s.Keyword.Pos, s.CloseBrace.Pos
+}
+
 type StmtEvent struct {
 	At        Token
 	Name      Token
 	Parameter Expr
-	Body      []Stmt
+	// Params contains the names of the payload parameters of custom events.
+	Params []Token
+	Body   []Stmt
 }
 
 func (s *StmtEvent) Accept(visitor StmtVisitor) error {
@@ -107,6 +153,8 @@ func (s *StmtEvent) Position() (start, end Position) {
 }
 
 type StmtCall struct {
+	// Await is the 'await' keyword in front of custom event calls which wait for all handlers to finish.
+	Await      Token
 	Name       Token
 	CloseParen Token
 	Parameters []Expr
@@ -117,6 +165,9 @@ func (s *StmtCall) Accept(visitor StmtVisitor) error {
 }
 
 func (s *StmtCall) Position() (start, end Position) {
+	if s.Await.Type == TkAwait {
+		return s.Await.Pos, s.CloseParen.Pos
+	}
 	return s.Name.Pos, s.CloseParen.Pos
 }
 
@@ -152,7 +203,9 @@ func (s *StmtIf) Position() (start, end Position) {
 }
 
 type StmtLoop struct {
-	Keyword   Token
+	Keyword Token
+	// Variable is only set for 'for x in ...' loops. The range or list is stored in Condition.
+	Variable  Token
 	Condition Expr
 	Body      []Stmt
 }
@@ -169,3 +222,76 @@ func (s *StmtLoop) Position() (start, end Position) {
 	}
 	return s.Keyword.Pos, end
 }
+
+type StmtReturn struct {
+	Keyword Token
+	Value   Expr
+}
+
+func (s *StmtReturn) Accept(visitor StmtVisitor) error {
+	return visitor.VisitReturn(s)
+}
+
+func (s *StmtReturn) Position() (start, end Position) {
+	if s.Value != nil {
+		_, end = s.Value.Position()
+	} else {
+		end = s.Keyword.EndPos
+	}
+	return s.Keyword.Pos, end
+}
+
+// StmtLoopControl is a 'break' or 'continue' statement.
+type StmtLoopControl struct {
+	Keyword Token
+}
+
+func (s *StmtLoopControl) Accept(visitor StmtVisitor) error {
+	return visitor.VisitLoopControl(s)
+}
+
+func (s *StmtLoopControl) Position() (start, end Position) {
+	return s.Keyword.Pos, s.Keyword.EndPos
+}
+
+type MatchCase struct {
+	Keyword Token
+	Values  []Expr
+	Body    []Stmt
+}
+
+type StmtMatch struct {
+	Keyword  Token
+	Subject  Expr
+	Cases    []MatchCase
+	ElseBody []Stmt
+}
+
+func (s *StmtMatch) Accept(visitor StmtVisitor) error {
+	return visitor.VisitMatch(s)
+}
+
+func (s *StmtMatch) Position() (start, end Position) {
+	_, end = s.Subject.Position()
+	return s.Keyword.Pos, end
+}
+
+// StmtImport is an 'import "path" as name' statement. Body contains the top level statements of the imported module.
+type StmtImport struct {
+	Keyword Token
+	Path    Token
+	Alias   Token
+	// File is the resolved path of the module.
+	File string
+	Body []Stmt
+	// Exports contains the names of the declarations of the module which are marked with 'export'.
+	Exports []Token
+}
+
+func (s *StmtImport) Accept(visitor StmtVisitor) error {
+	return visitor.VisitImport(s)
+}
+
+func (s *StmtImport) Position() (start, end Position) {
+	return s.Keyword.Pos, s.Alias.EndPos
+}
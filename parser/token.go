@@ -11,9 +11,13 @@ const (
 	TkCloseParen
 	TkOpenBracket
 	TkCloseBracket
+	TkOpenBrace
+	TkCloseBrace
 	TkColon
 	TkDot
+	TkDotDot
 	TkComma
+	TkQuestionMark
 
 	TkBang
 	TkOr
@@ -29,6 +33,7 @@ const (
 	TkDivideAssign
 	TkModulus
 	TkModulusAssign
+	TkPower
 
 	TkAssign
 	TkEqual
@@ -47,11 +52,27 @@ const (
 	TkConst
 	TkFunc
 	TkEvent
+	TkTypeDef
+	TkEnum
+	TkReturn
+	TkBreak
+	TkContinue
+	TkIn
+	TkMatch
+	TkCase
+	TkAwait
+	TkImport
+	TkExport
 
 	TkIdentifier
 	TkLiteral
+	TkInterpolationStart
+	TkInterpolationMiddle
+	TkInterpolationEnd
 	TkType
 	TkPreprocessor
+	// TkModuleEnd marks the end of the tokens of an imported module. It is inserted by the preprocessor.
+	TkModuleEnd
 
 	TkEOF
 )
@@ -66,6 +87,7 @@ const (
 
 	DTNumberList DataType = "number[]"
 	DTStringList DataType = "string[]"
+	DTBoolList   DataType = "boolean[]"
 )
 
 type Position struct {